@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"hibp_pw_downloader.rasc.ch/hibp/benchmark"
+	"hibp_pw_downloader.rasc.ch/hibp/client"
+)
+
+// newBenchmarkCommand returns the "benchmark" subcommand, which samples
+// prefixes at several parallelism levels and reports a suggested
+// --parallelism value, instead of downloading the full corpus.
+func newBenchmarkCommand() *cobra.Command {
+	var sampleSize int
+	var levelsFlag string
+	var ntlm bool
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "benchmark",
+		Short: "Measure request latency and throughput at several --parallelism levels and suggest one",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			levels, err := parseParallelismLevels(levelsFlag)
+			if err != nil {
+				return err
+			}
+
+			c := client.New(&http.Client{})
+			c.NTLM = ntlm
+
+			result, err := benchmark.Run(context.Background(), c, sampleSize, levels)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(result)
+			}
+			printBenchmarkResult(cmd.OutOrStdout(), result)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&sampleSize, "sample-size", benchmark.DefaultSampleSize, "Number of random prefixes to fetch at each parallelism level.")
+	cmd.Flags().StringVar(&levelsFlag, "parallelism-levels", "1,2,4,8,16,24", "Comma-separated parallelism levels to benchmark.")
+	cmd.Flags().BoolVar(&ntlm, "ntlm", false, "When set, benchmarks NTLM hash requests instead of SHA1.")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the result as JSON instead of a table.")
+
+	return cmd
+}
+
+func parseParallelismLevels(s string) ([]int, error) {
+	parts := strings.Split(s, ",")
+	levels := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --parallelism-levels %q: %w", s, err)
+		}
+		levels = append(levels, n)
+	}
+	return levels, nil
+}
+
+func printBenchmarkResult(w io.Writer, result benchmark.Result) {
+	fmt.Fprintf(w, "Sample size: %d prefixes\n\n", result.SampleSize)
+	fmt.Fprintf(w, "%-12s%-10s%-10s%-10s%-14s%-12s%-10s%-8s\n",
+		"parallelism", "p50(ms)", "p95(ms)", "p99(ms)", "prefixes/s", "MB/s", "hit%", "retries")
+	for _, l := range result.Levels {
+		fmt.Fprintf(w, "%-12d%-10d%-10d%-10d%-14.1f%-12.2f%-10d%-8d\n",
+			l.Parallelism, l.MedianLatencyMs, l.P95LatencyMs, l.P99LatencyMs,
+			l.ThroughputPerSec, l.ThroughputMBps, l.HitRatePercent, l.Retries)
+	}
+	fmt.Fprintf(w, "\nSuggested: --parallelism=%d\n", result.Suggested)
+}
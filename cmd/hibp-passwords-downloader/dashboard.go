@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"hibp_pw_downloader.rasc.ch/hibp/downloader"
+	"hibp_pw_downloader.rasc.ch/hibp/stats"
+)
+
+// dashboardProgress renders a live, redrawing dashboard: one line per
+// worker showing its current prefix and a rolling requests/s, a total
+// line, and a footer with running Cloudflare hit-rate, average latency,
+// retries seen in the last minute and an estimated time to completion.
+type dashboardProgress struct {
+	w     io.Writer
+	stats *stats.Stats
+
+	start time.Time
+	stop  chan struct{}
+	done  sync.WaitGroup
+
+	mu         sync.Mutex
+	total      int
+	completed  int
+	workers    []workerState
+	retries    []time.Time
+	linesDrawn int
+}
+
+type workerState struct {
+	hexPrefix string
+	rate      float64 // exponentially smoothed completions/second
+	lastDone  time.Time
+}
+
+func newDashboardProgress(w io.Writer, s *stats.Stats) *dashboardProgress {
+	return &dashboardProgress{w: w, stats: s}
+}
+
+func (d *dashboardProgress) Init(total, workers int) {
+	d.total = total
+	d.workers = make([]workerState, workers)
+	d.start = time.Now()
+	d.stop = make(chan struct{})
+
+	d.done.Add(1)
+	go d.loop()
+}
+
+func (d *dashboardProgress) loop() {
+	defer d.done.Done()
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.render()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+func (d *dashboardProgress) StartPrefix(workerID int, hexPrefix string) {
+	d.mu.Lock()
+	d.workers[workerID].hexPrefix = hexPrefix
+	d.mu.Unlock()
+}
+
+func (d *dashboardProgress) CompletePrefix(workerID int, event downloader.PrefixEvent) {
+	now := time.Now()
+
+	d.mu.Lock()
+	d.completed++
+
+	w := &d.workers[workerID]
+	if !w.lastDone.IsZero() {
+		if interval := now.Sub(w.lastDone).Seconds(); interval > 0 {
+			instant := 1 / interval
+			if w.rate == 0 {
+				w.rate = instant
+			} else {
+				w.rate = 0.3*instant + 0.7*w.rate
+			}
+		}
+	}
+	w.lastDone = now
+
+	for i := 0; i < event.Retries; i++ {
+		d.retries = append(d.retries, now)
+	}
+	d.mu.Unlock()
+}
+
+func (d *dashboardProgress) Done() {
+	close(d.stop)
+	d.done.Wait()
+	d.render()
+	fmt.Fprintln(d.w)
+}
+
+// finish satisfies the signature newProgressReporter expects to call once
+// the run has completed; the dashboard has nothing further to flush.
+func (d *dashboardProgress) finish() error {
+	return nil
+}
+
+func (d *dashboardProgress) render() {
+	d.mu.Lock()
+	completed, total := d.completed, d.total
+	workers := append([]workerState(nil), d.workers...)
+
+	cutoff := time.Now().Add(-time.Minute)
+	fresh := d.retries[:0]
+	for _, t := range d.retries {
+		if t.After(cutoff) {
+			fresh = append(fresh, t)
+		}
+	}
+	d.retries = fresh
+	retriesLastMinute := len(fresh)
+	d.mu.Unlock()
+
+	var eta time.Duration
+	if elapsed := time.Since(d.start).Seconds(); completed > 0 && elapsed > 0 {
+		rate := float64(completed) / elapsed
+		if rate > 0 {
+			eta = time.Duration(float64(total-completed) / rate * float64(time.Second)).Round(time.Second)
+		}
+	}
+
+	if d.linesDrawn > 0 {
+		fmt.Fprintf(d.w, "\x1b[%dA", d.linesDrawn)
+	}
+
+	lines := 0
+	for i, w := range workers {
+		label := w.hexPrefix
+		if label == "" {
+			label = "idle"
+		}
+		fmt.Fprintf(d.w, "\x1b[2Kworker %2d: %-8s %6.1f req/s\n", i, label, w.rate)
+		lines++
+	}
+
+	pct := 0.0
+	if total > 0 {
+		pct = float64(completed) / float64(total) * 100
+	}
+	fmt.Fprintf(d.w, "\x1b[2Ktotal: %d/%d (%.1f%%)\n", completed, total, pct)
+	lines++
+
+	fmt.Fprintf(d.w, "\x1b[2Khit rate %d%%  avg latency %dms  retries/min %d  eta %s\n",
+		d.stats.HitRatePercent(), d.stats.AverageRequestTimeMillis(), retriesLastMinute, eta)
+	lines++
+
+	d.linesDrawn = lines
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"hibp_pw_downloader.rasc.ch/hibp/downloader"
+)
+
+// jsonLinesProgress emits one JSON object per line for each downloader
+// event, for headless or CI runs that want to monitor progress externally
+// instead of rendering a terminal progress bar.
+type jsonLinesProgress struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newJSONLinesProgress(w io.Writer) *jsonLinesProgress {
+	return &jsonLinesProgress{enc: json.NewEncoder(w)}
+}
+
+type progressLine struct {
+	Event       string `json:"event"`
+	Total       int    `json:"total,omitempty"`
+	Workers     int    `json:"workers,omitempty"`
+	WorkerID    int    `json:"workerID,omitempty"`
+	Prefix      string `json:"prefix,omitempty"`
+	CacheStatus string `json:"cacheStatus,omitempty"`
+	DurationMs  int64  `json:"durationMs,omitempty"`
+	Retries     int    `json:"retries,omitempty"`
+	Skipped     bool   `json:"skipped,omitempty"`
+}
+
+func (p *jsonLinesProgress) Init(total, workers int) {
+	p.write(progressLine{Event: "init", Total: total, Workers: workers})
+}
+
+func (p *jsonLinesProgress) StartPrefix(int, string) {}
+
+func (p *jsonLinesProgress) CompletePrefix(workerID int, event downloader.PrefixEvent) {
+	p.write(progressLine{
+		Event:       "prefix",
+		WorkerID:    workerID,
+		Prefix:      event.HexPrefix,
+		CacheStatus: event.CacheStatus,
+		DurationMs:  event.Duration.Milliseconds(),
+		Retries:     event.Retries,
+		Skipped:     event.Skipped,
+	})
+}
+
+func (p *jsonLinesProgress) Done() {
+	p.write(progressLine{Event: "done"})
+}
+
+func (p *jsonLinesProgress) write(line progressLine) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_ = p.enc.Encode(line)
+}
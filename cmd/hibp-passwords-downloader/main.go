@@ -0,0 +1,363 @@
+// Command hibp-passwords-downloader downloads the Have I Been Pwned Pwned
+// Passwords hash range corpus to a local file or folder. It is a thin Cobra
+// wrapper around the hibp/downloader package.
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+
+	"hibp_pw_downloader.rasc.ch/hibp/client"
+	"hibp_pw_downloader.rasc.ch/hibp/downloader"
+	"hibp_pw_downloader.rasc.ch/hibp/manifest"
+	"hibp_pw_downloader.rasc.ch/hibp/output"
+	"hibp_pw_downloader.rasc.ch/hibp/stats"
+	"hibp_pw_downloader.rasc.ch/internal/cache"
+)
+
+type config struct {
+	OutputFileOrFolder string
+	Parallelism        int
+	Overwrite          bool
+	Resume             bool
+	SingleFile         bool
+	FetchNtlm          bool
+	Refresh            bool
+	Verify             bool
+	Format             string
+	ChunkSize          int
+	CacheDir           string
+	CacheMaxAge        string
+	Dashboard          bool
+	JSONProgress       bool
+	BloomN             uint64
+	BloomFPR           float64
+}
+
+// outputFormat resolves the effective output format: --format takes
+// precedence when given; otherwise it falls back to the legacy --single
+// flag for backwards compatibility.
+func (cfg config) outputFormat() (string, error) {
+	switch cfg.Format {
+	case "":
+		if cfg.SingleFile {
+			return "single", nil
+		}
+		return "files", nil
+	case "single", "files", "chunks", "bloom", "sorted-bin":
+		return cfg.Format, nil
+	default:
+		return "", fmt.Errorf("unknown --format %q: must be single, files, chunks, bloom or sorted-bin", cfg.Format)
+	}
+}
+
+func main() {
+	var cfg config
+	cmd := &cobra.Command{
+		Use:   "hibp-passwords-downloader [outputFileOrFolder]",
+		Short: "Downloads Have I Been Pwned passwords hashes lists to find compromised passwords",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				cfg.OutputFileOrFolder = args[0]
+			} else {
+				cfg.OutputFileOrFolder = "hibp-passwords.txt"
+			}
+			if cfg.Parallelism == 0 {
+				cfg.Parallelism = runtime.NumCPU() * 2
+				if cfg.Parallelism > 8 {
+					cfg.Parallelism = 8
+				}
+			}
+			return run(cfg)
+		},
+	}
+
+	cmd.Flags().IntVarP(&cfg.Parallelism, "parallelism", "p", 0, "The number of parallel requests to make to Have I Been Pwned to download the hash ranges. If omitted, defaults to twice the number of processors on the machine, at most 8.")
+	cmd.Flags().BoolVarP(&cfg.Overwrite, "overwrite", "o", false, "When set, overwrite any existing files while writing the results. Defaults to false.")
+	cmd.Flags().BoolVarP(&cfg.SingleFile, "single", "s", true, "When set, writes the hash ranges into a single .txt file. Otherwise downloads ranges to individual files into a subfolder. If ommited defaults to single file.")
+	cmd.Flags().BoolVarP(&cfg.FetchNtlm, "ntlm", "n", false, "When set, fetches NTLM hashes instead of SHA1.")
+	cmd.Flags().BoolVarP(&cfg.Resume, "resume", "r", false, "When individual files are used, resume download of existing files.")
+	cmd.Flags().BoolVar(&cfg.Refresh, "refresh", false, "When individual files are used, ignore the manifest's recorded ETags and force a full re-download of every prefix.")
+	cmd.Flags().BoolVar(&cfg.Verify, "verify", false, "When individual files are used, re-hash existing files against the manifest to detect corruption instead of downloading.")
+	cmd.Flags().StringVar(&cfg.Format, "format", "", "Output format: single, files, chunks, bloom or sorted-bin. Overrides --single when set.")
+	cmd.Flags().IntVar(&cfg.ChunkSize, "chunk-size", output.DefaultChunkSize, "Number of hash prefixes packed into each chunk archive when --format=chunks.")
+	cmd.Flags().Uint64Var(&cfg.BloomN, "bloom-n", output.DefaultBloomN, "Expected number of hashes to size the filter for when --format=bloom.")
+	cmd.Flags().Float64Var(&cfg.BloomFPR, "bloom-fpr", output.DefaultBloomFPR, "Target false-positive rate when --format=bloom.")
+	cmd.Flags().StringVar(&cfg.CacheDir, "cache-dir", defaultCacheDir(), "Directory used to cache downloaded ranges by ETag, saving a network request on a later run with the same content.")
+	cmd.Flags().StringVar(&cfg.CacheMaxAge, "cache-max-age", "-1", "How long a cached range stays valid: -1 never expires, 0 disables the cache, or a duration such as 24h.")
+	cmd.Flags().BoolVar(&cfg.Dashboard, "dashboard", false, "Render a live dashboard with one line per worker, a global progress bar and a running-stats footer, instead of a single progress bar.")
+	cmd.Flags().BoolVar(&cfg.JSONProgress, "json-progress", false, "Emit one JSON line per completed prefix on stdout instead of any progress bar, for headless or CI runs.")
+
+	cmd.AddCommand(newVerifyChunksCommand())
+	cmd.AddCommand(newBenchmarkCommand())
+
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// defaultCacheDir returns the cache directory to use when --cache-dir is
+// not given: the OS user cache directory (honouring XDG_CACHE_HOME on
+// Linux), or an empty string if it cannot be determined, which disables
+// the cache by default.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, cache.DirName)
+}
+
+// newVerifyChunksCommand returns the "verify" companion subcommand for the
+// chunks output format, re-checking every chunk archive's recorded
+// SHA-256. It is distinct from the root --verify flag, which checks the
+// individual-files manifest instead.
+func newVerifyChunksCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify <chunk-folder>",
+		Short: "Re-check the SHA-256 of every chunk archive in a --format=chunks output folder",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := output.VerifyChunks(args[0])
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Checked:   %d\n", result.Checked)
+			fmt.Printf("Missing:   %d\n", len(result.Missing))
+			fmt.Printf("Corrupted: %d\n", len(result.Corrupted))
+			for _, poolID := range result.Missing {
+				fmt.Printf("  missing:   %s\n", poolID)
+			}
+			for _, poolID := range result.Corrupted {
+				fmt.Printf("  corrupted: %s\n", poolID)
+			}
+
+			if len(result.Missing) > 0 || len(result.Corrupted) > 0 {
+				return fmt.Errorf("chunk verification failed: %d missing, %d corrupted", len(result.Missing), len(result.Corrupted))
+			}
+			return nil
+		},
+	}
+}
+
+func run(cfg config) error {
+	format, err := cfg.outputFormat()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Verify {
+		return verify(cfg, format)
+	}
+
+	downloadFolder, err := prepareFolders(cfg, format)
+	if err != nil {
+		return err
+	}
+
+	var writer output.Writer
+	var m *manifest.Manifest
+	switch format {
+	case "single":
+		writer = output.NewSingleFileWriter(cfg.OutputFileOrFolder, downloadFolder)
+	case "chunks":
+		writer = output.NewChunkWriter(downloadFolder, cfg.ChunkSize)
+	case "bloom":
+		writer, err = output.NewBloomWriter(cfg.OutputFileOrFolder, cfg.BloomN, cfg.BloomFPR)
+		if err != nil {
+			return err
+		}
+	case "sorted-bin":
+		writer = output.NewSortedBinWriter(cfg.OutputFileOrFolder, downloadFolder, cfg.FetchNtlm)
+	default: // "files"
+		writer = output.NewPerPrefixWriter(downloadFolder)
+		// The manifest lets a rerun of the same folder skip prefixes HIBP
+		// reports as unchanged. It only makes sense here: the single-file
+		// mode's staging folder is removed once the merged output is
+		// written, so there is nothing left to compare against next time.
+		m, err = manifest.Load(filepath.Join(downloadFolder, ".manifest.json"))
+		if err != nil {
+			return err
+		}
+	}
+
+	c := client.New(&http.Client{})
+	c.NTLM = cfg.FetchNtlm
+
+	fetcher, err := wrapWithCache(cfg, c)
+	if err != nil {
+		return err
+	}
+
+	s := stats.New()
+	progress, finish := newProgressReporter(cfg, s)
+
+	d := downloader.New(fetcher, writer, s, downloader.Options{
+		Parallelism: cfg.Parallelism,
+		Resume:      cfg.Resume,
+		Refresh:     cfg.Refresh,
+	})
+	d.Progress = progress
+	d.Manifest = m
+
+	if err := d.Run(context.Background()); err != nil {
+		return err
+	}
+
+	if err := finish(); err != nil {
+		return err
+	}
+
+	s.Print(os.Stdout)
+
+	return nil
+}
+
+// newProgressReporter picks the ProgressReporter matching cfg's UI flags
+// and returns it along with a func to call once the run has finished.
+func newProgressReporter(cfg config, s *stats.Stats) (downloader.ProgressReporter, func() error) {
+	if cfg.JSONProgress {
+		r := newJSONLinesProgress(os.Stdout)
+		return r, func() error { return nil }
+	}
+	if cfg.Dashboard {
+		r := newDashboardProgress(os.Stdout, s)
+		return r, r.finish
+	}
+	bar := progressbar.Default(downloader.TotalPrefixes)
+	return &barProgress{bar: bar}, bar.Finish
+}
+
+// wrapWithCache decorates c with an on-disk cache when caching is enabled,
+// or returns c unchanged if --cache-dir is empty or --cache-max-age
+// disables it.
+func wrapWithCache(cfg config, c *client.Client) (client.Fetcher, error) {
+	if cfg.CacheDir == "" {
+		return c, nil
+	}
+
+	maxAge, err := cache.ParseMaxAge(cfg.CacheMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --cache-max-age %q: %w", cfg.CacheMaxAge, err)
+	}
+	if maxAge == cache.Disabled {
+		return c, nil
+	}
+
+	if err := os.MkdirAll(cfg.CacheDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return cache.New(cfg.CacheDir, maxAge, c), nil
+}
+
+// verify re-hashes the individual files in cfg.OutputFileOrFolder against
+// their recorded manifest entries and reports any that are missing or no
+// longer match. It only applies to the "files" format, which is the only
+// one that keeps a per-prefix manifest around after a run; chunks have
+// their own "verify" subcommand, and the other formats keep no per-prefix
+// state to re-check.
+func verify(cfg config, format string) error {
+	if format != "files" {
+		if format == "chunks" {
+			return fmt.Errorf("--verify does not support --format=chunks, use the `verify` subcommand instead")
+		}
+		return fmt.Errorf("--verify requires individual files mode, rerun with --format=files (or --single=false)")
+	}
+
+	m, err := manifest.Load(filepath.Join(cfg.OutputFileOrFolder, ".manifest.json"))
+	if err != nil {
+		return err
+	}
+
+	result, err := m.Verify(output.NewPerPrefixWriter(cfg.OutputFileOrFolder))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Checked:   %d\n", result.Checked)
+	fmt.Printf("Missing:   %d\n", len(result.Missing))
+	fmt.Printf("Corrupted: %d\n", len(result.Corrupted))
+	for _, hexPrefix := range result.Missing {
+		fmt.Printf("  missing:   %s\n", hexPrefix)
+	}
+	for _, hexPrefix := range result.Corrupted {
+		fmt.Printf("  corrupted: %s\n", hexPrefix)
+	}
+
+	if len(result.Missing) > 0 || len(result.Corrupted) > 0 {
+		return fmt.Errorf("verification failed: %d missing, %d corrupted", len(result.Missing), len(result.Corrupted))
+	}
+	return nil
+}
+
+// prepareFolders validates and creates the output location for cfg,
+// returning the folder per-prefix or chunk files are staged in.
+func prepareFolders(cfg config, format string) (string, error) {
+	if format == "bloom" {
+		// BloomWriter holds the whole filter in memory and writes a single
+		// file on Finalize; there is no staging folder to create.
+		if _, err := os.Stat(cfg.OutputFileOrFolder); !os.IsNotExist(err) {
+			if !cfg.Overwrite {
+				return "", fmt.Errorf("output file %q already exists. Use -o if you want to overwrite it", cfg.OutputFileOrFolder)
+			}
+		}
+		return "", nil
+	}
+
+	if format == "single" || format == "sorted-bin" {
+		// Both formats merge staged per-prefix files into a single output
+		// file, so the staging folder needs a name distinct from it.
+		if _, err := os.Stat(cfg.OutputFileOrFolder); !os.IsNotExist(err) {
+			if !cfg.Overwrite {
+				return "", fmt.Errorf("output file %q already exists. Use -o if you want to overwrite it", cfg.OutputFileOrFolder)
+			}
+		}
+		downloadFolder := cfg.OutputFileOrFolder + "_" + time.Now().Format("2006-01-02_15-04-05")
+		if err := os.Mkdir(downloadFolder, os.ModePerm); err != nil {
+			return "", err
+		}
+		return downloadFolder, nil
+	}
+
+	if _, err := os.Stat(cfg.OutputFileOrFolder); !os.IsNotExist(err) {
+		containsFiles := false
+		if files, err := os.ReadDir(cfg.OutputFileOrFolder); err == nil {
+			containsFiles = len(files) > 0
+		}
+		if !cfg.Resume && !cfg.Overwrite && containsFiles {
+			return "", fmt.Errorf("output folder %q already exists and is not empty. Use -o if you want to overwrite it", cfg.OutputFileOrFolder)
+		}
+	} else if err := os.Mkdir(cfg.OutputFileOrFolder, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	return cfg.OutputFileOrFolder, nil
+}
+
+// barProgress reports downloader progress through a single
+// progressbar.ProgressBar, advancing it once per completed prefix.
+type barProgress struct {
+	bar *progressbar.ProgressBar
+}
+
+func (p *barProgress) Init(total, _ int) {
+	p.bar.ChangeMax(total)
+}
+
+func (p *barProgress) StartPrefix(int, string) {}
+
+func (p *barProgress) CompletePrefix(int, downloader.PrefixEvent) {
+	_ = p.bar.Add(1)
+}
+
+func (p *barProgress) Done() {}
@@ -0,0 +1,163 @@
+// Package benchmark measures how a client.Fetcher performs at several
+// worker-parallelism levels, so a caller can pick a good --parallelism
+// value for their link before committing to a multi-hour full download.
+package benchmark
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alitto/pond"
+
+	"hibp_pw_downloader.rasc.ch/hibp/client"
+	"hibp_pw_downloader.rasc.ch/hibp/downloader"
+)
+
+// DefaultSampleSize is the number of prefixes fetched at each parallelism
+// level when none is given.
+const DefaultSampleSize = 1000
+
+// DefaultParallelisms are the parallelism levels benchmarked when none are
+// given.
+var DefaultParallelisms = []int{1, 2, 4, 8, 16, 24}
+
+// Level reports the measurements collected for a single parallelism level.
+type Level struct {
+	Parallelism      int     `json:"parallelism"`
+	MedianLatencyMs  int64   `json:"medianLatencyMs"`
+	P95LatencyMs     int64   `json:"p95LatencyMs"`
+	P99LatencyMs     int64   `json:"p99LatencyMs"`
+	ThroughputPerSec float64 `json:"throughputPrefixesPerSec"`
+	ThroughputMBps   float64 `json:"throughputMBps"`
+	HitRatePercent   uint64  `json:"hitRatePercent"`
+	Retries          int     `json:"retries"`
+	HashesDownloaded uint64  `json:"hashesDownloaded"`
+}
+
+// Result is the outcome of benchmarking every requested parallelism level.
+type Result struct {
+	SampleSize int     `json:"sampleSize"`
+	Levels     []Level `json:"levels"`
+	// Suggested is the parallelism level recommended by Run, picked at the
+	// knee of the throughput curve.
+	Suggested int `json:"suggestedParallelism"`
+}
+
+// Run benchmarks fetcher at each of parallelisms, fetching the same random
+// sample of sampleSize hash prefixes at every level so the levels are
+// comparable. Results are not written anywhere; fetcher's response bodies
+// are only used to record their size and hash count.
+func Run(ctx context.Context, fetcher client.Fetcher, sampleSize int, parallelisms []int) (Result, error) {
+	prefixes := samplePrefixes(sampleSize)
+
+	result := Result{SampleSize: len(prefixes)}
+	for _, p := range parallelisms {
+		level, err := runLevel(ctx, fetcher, prefixes, p)
+		if err != nil {
+			return Result{}, err
+		}
+		result.Levels = append(result.Levels, level)
+	}
+	result.Suggested = suggestParallelism(result.Levels)
+
+	return result, nil
+}
+
+func samplePrefixes(n int) []string {
+	prefixes := make([]string, n)
+	for i := range prefixes {
+		prefixes[i] = fmt.Sprintf("%05X", rand.Intn(downloader.TotalPrefixes))
+	}
+	return prefixes
+}
+
+func runLevel(ctx context.Context, fetcher client.Fetcher, prefixes []string, parallelism int) (Level, error) {
+	pool := pond.New(parallelism, len(prefixes))
+
+	var mu sync.Mutex
+	var latencies []time.Duration
+	var total, hits, retries int
+	var totalBytes, hashes uint64
+
+	start := time.Now()
+	for _, hexPrefix := range prefixes {
+		hexPrefix := hexPrefix
+		pool.Submit(func() {
+			resp, err := fetcher.Fetch(ctx, hexPrefix, "")
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			latencies = append(latencies, resp.Duration)
+			total++
+			if resp.CacheStatus == "HIT" {
+				hits++
+			}
+			retries += resp.Retries
+			totalBytes += uint64(len(resp.Body))
+			hashes += uint64(bytes.Count(resp.Body, []byte("\n")) + 1)
+		})
+	}
+	pool.StopAndWait()
+	elapsed := time.Since(start)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	level := Level{
+		Parallelism:      parallelism,
+		MedianLatencyMs:  percentile(latencies, 0.50).Milliseconds(),
+		P95LatencyMs:     percentile(latencies, 0.95).Milliseconds(),
+		P99LatencyMs:     percentile(latencies, 0.99).Milliseconds(),
+		ThroughputPerSec: float64(total) / elapsed.Seconds(),
+		ThroughputMBps:   float64(totalBytes) / elapsed.Seconds() / 1e6,
+		Retries:          retries,
+		HashesDownloaded: hashes,
+	}
+	if total > 0 {
+		level.HitRatePercent = uint64(hits * 100 / total)
+	}
+
+	return level, nil
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+// suggestParallelism picks the parallelism level at the knee of the
+// throughput curve: the level just before the first one whose throughput
+// improves by less than 10% over it. If throughput keeps improving by at
+// least 10% at every level, the highest-throughput level is suggested
+// instead.
+func suggestParallelism(levels []Level) int {
+	if len(levels) == 0 {
+		return 0
+	}
+
+	best := levels[0]
+	for i := 1; i < len(levels); i++ {
+		prev, cur := levels[i-1], levels[i]
+		if prev.ThroughputPerSec > 0 {
+			gain := (cur.ThroughputPerSec - prev.ThroughputPerSec) / prev.ThroughputPerSec
+			if gain < 0.10 {
+				return prev.Parallelism
+			}
+		}
+		if cur.ThroughputPerSec > best.ThroughputPerSec {
+			best = cur
+		}
+	}
+
+	return best.Parallelism
+}
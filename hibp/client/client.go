@@ -0,0 +1,149 @@
+// Package client fetches individual SHA-1/NTLM hash-prefix ranges from the
+// Have I Been Pwned Pwned Passwords API, transparently decoding the
+// brotli-compressed response body and retrying transient failures.
+package client
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/avast/retry-go"
+)
+
+// DefaultBaseURL is the Pwned Passwords range endpoint used when a Client is
+// constructed with New.
+const DefaultBaseURL = "https://api.pwnedpasswords.com/range/"
+
+// Fetcher fetches a single hash-prefix range, optionally sending etag as an
+// If-None-Match value. *Client is the base implementation; other
+// implementations (e.g. an on-disk cache) can decorate it to add caching or
+// deduplication without the downloader needing to know the difference.
+type Fetcher interface {
+	Fetch(ctx context.Context, hexPrefix string, etag string) (*Response, error)
+}
+
+var _ Fetcher = (*Client)(nil)
+
+// Response is the decoded result of fetching a single hash-prefix range.
+type Response struct {
+	// HexPrefix is the 5-character hash prefix the response was fetched for.
+	HexPrefix string
+	// Body holds the decompressed, newline-separated suffix list. It is nil
+	// when NotModified is true.
+	Body []byte
+	// ETag is the strong ETag HIBP returned for this range, usable as the
+	// If-None-Match value on a subsequent request.
+	ETag string
+	// CacheStatus is the raw Cf-Cache-Status response header value.
+	CacheStatus string
+	// NotModified reports whether the server responded 304 Not Modified in
+	// response to an If-None-Match request.
+	NotModified bool
+	// Duration is the wall-clock time the request took, retries included.
+	Duration time.Duration
+	// Retries is the number of attempts beyond the first that were needed
+	// to get this response.
+	Retries int
+}
+
+// Client fetches hash-prefix ranges over HTTP. The zero value is not usable;
+// construct one with New.
+type Client struct {
+	// HTTPClient performs the actual requests. Callers may supply their own,
+	// e.g. to customise timeouts or transport-level settings.
+	HTTPClient *http.Client
+	// BaseURL is the range endpoint to fetch prefixes from, without a
+	// trailing prefix. Defaults to DefaultBaseURL.
+	BaseURL string
+	// NTLM selects NTLM hashes instead of SHA-1 by appending
+	// "?mode=ntlm" to every request.
+	NTLM bool
+	// Attempts is the total number of tries per request, the first one
+	// included. Defaults to 10 if zero.
+	Attempts uint
+}
+
+// New returns a Client that fetches from DefaultBaseURL using httpClient. If
+// httpClient is nil, http.DefaultClient's zero-value equivalent (&http.Client{})
+// is used.
+func New(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &Client{
+		HTTPClient: httpClient,
+		BaseURL:    DefaultBaseURL,
+	}
+}
+
+// Fetch downloads and decodes the range for hexPrefix. If etag is non-empty,
+// it is sent as If-None-Match, and the server may reply 304 Not Modified, in
+// which case the returned Response has NotModified set and a nil Body.
+func (c *Client) Fetch(ctx context.Context, hexPrefix string, etag string) (*Response, error) {
+	url := c.BaseURL + hexPrefix
+	if c.NTLM {
+		url += "?mode=ntlm"
+	}
+
+	attempts := c.Attempts
+	if attempts == 0 {
+		attempts = 10
+	}
+
+	var httpResp *http.Response
+	var retries int
+	start := time.Now()
+	err := retry.Do(
+		func() error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return retry.Unrecoverable(err)
+			}
+			req.Header.Set("User-Agent", "hibp-downloader")
+			req.Header.Set("Accept-Encoding", "br")
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+
+			httpResp, err = c.HTTPClient.Do(req)
+			return err
+		},
+		retry.Attempts(attempts),
+		retry.Context(ctx),
+		retry.OnRetry(func(n uint, err error) {
+			retries = int(n) + 1
+			log.Printf("Retrying request for prefix %s after error: %v", hexPrefix, err)
+		}),
+	)
+	duration := time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	resp := &Response{
+		HexPrefix:   hexPrefix,
+		ETag:        httpResp.Header.Get("ETag"),
+		CacheStatus: httpResp.Header.Get("Cf-Cache-Status"),
+		Duration:    duration,
+		Retries:     retries,
+	}
+
+	if httpResp.StatusCode == http.StatusNotModified {
+		resp.NotModified = true
+		return resp, nil
+	}
+
+	reader := brotli.NewReader(httpResp.Body)
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = body
+
+	return resp, nil
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func brotliCompress(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := brotli.NewWriter(&buf)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatalf("brotli write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("brotli close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestClientFetchDecodesBrotliAndHeaders(t *testing.T) {
+	body := "0000000000000000000A:5\n"
+
+	var gotPath, gotEncoding, gotIfNoneMatch string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotEncoding = r.Header.Get("Accept-Encoding")
+		gotIfNoneMatch = r.Header.Get("If-None-Match")
+		w.Header().Set("ETag", `"abc123"`)
+		w.Header().Set("Cf-Cache-Status", "HIT")
+		w.Write(brotliCompress(t, body))
+	}))
+	defer srv.Close()
+
+	c := New(nil)
+	c.BaseURL = srv.URL + "/"
+	resp, err := c.Fetch(context.Background(), "00000", `"prev-etag"`)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+
+	if gotPath != "/00000" {
+		t.Errorf("request path = %q, want /00000", gotPath)
+	}
+	if gotEncoding != "br" {
+		t.Errorf("Accept-Encoding = %q, want br", gotEncoding)
+	}
+	if gotIfNoneMatch != `"prev-etag"` {
+		t.Errorf("If-None-Match = %q, want %q", gotIfNoneMatch, `"prev-etag"`)
+	}
+	if string(resp.Body) != body {
+		t.Errorf("Body = %q, want %q", resp.Body, body)
+	}
+	if resp.ETag != `"abc123"` {
+		t.Errorf("ETag = %q, want %q", resp.ETag, `"abc123"`)
+	}
+	if resp.CacheStatus != "HIT" {
+		t.Errorf("CacheStatus = %q, want HIT", resp.CacheStatus)
+	}
+	if resp.NotModified {
+		t.Error("NotModified = true, want false")
+	}
+}
+
+func TestClientFetchNTLMAppendsModeQueryParam(t *testing.T) {
+	var gotQuery url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		w.Write(brotliCompress(t, "suffix:1\n"))
+	}))
+	defer srv.Close()
+
+	c := New(nil)
+	c.BaseURL = srv.URL + "/"
+	c.NTLM = true
+	if _, err := c.Fetch(context.Background(), "00000", ""); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+
+	if gotQuery.Get("mode") != "ntlm" {
+		t.Errorf("mode query param = %q, want ntlm", gotQuery.Get("mode"))
+	}
+}
+
+func TestClientFetchNotModified(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc123"`)
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+
+	c := New(nil)
+	c.BaseURL = srv.URL + "/"
+	resp, err := c.Fetch(context.Background(), "00000", `"abc123"`)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if !resp.NotModified {
+		t.Error("NotModified = false, want true")
+	}
+	if resp.Body != nil {
+		t.Errorf("Body = %q, want nil", resp.Body)
+	}
+}
+
+// dropConnection hijacks the connection and closes it without writing a
+// response, simulating a transport-level failure that retry.Do retries on
+// (Client only retries errors from HTTPClient.Do, not HTTP status codes).
+func dropConnection(t *testing.T, w http.ResponseWriter) {
+	t.Helper()
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		t.Fatal("ResponseWriter does not support hijacking")
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		t.Fatalf("hijack: %v", err)
+	}
+	conn.Close()
+}
+
+func TestClientFetchRetriesThenSucceeds(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= 2 {
+			dropConnection(t, w)
+			return
+		}
+		w.Write(brotliCompress(t, "suffix:1\n"))
+	}))
+	defer srv.Close()
+
+	c := New(nil)
+	c.BaseURL = srv.URL + "/"
+	resp, err := c.Fetch(context.Background(), "00000", "")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if resp.Retries != 2 {
+		t.Errorf("Retries = %d, want 2", resp.Retries)
+	}
+	if string(resp.Body) != "suffix:1\n" {
+		t.Errorf("Body = %q, want %q", resp.Body, "suffix:1\n")
+	}
+}
+
+func TestClientFetchExhaustsAttemptsOnPersistentFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dropConnection(t, w)
+	}))
+	defer srv.Close()
+
+	c := New(nil)
+	c.BaseURL = srv.URL + "/"
+	c.Attempts = 2
+	if _, err := c.Fetch(context.Background(), "00000", ""); err == nil {
+		t.Error("Fetch() = nil error after every attempt failed, want an error")
+	}
+}
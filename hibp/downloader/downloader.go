@@ -0,0 +1,230 @@
+// Package downloader orchestrates fetching every Pwned Passwords hash-prefix
+// range through a worker pool and writing the results through a
+// pluggable output.Writer.
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alitto/pond"
+
+	"hibp_pw_downloader.rasc.ch/hibp/client"
+	"hibp_pw_downloader.rasc.ch/hibp/manifest"
+	"hibp_pw_downloader.rasc.ch/hibp/output"
+	"hibp_pw_downloader.rasc.ch/hibp/stats"
+)
+
+// TotalPrefixes is the number of 5-hex-digit hash prefixes HIBP serves
+// ranges for (0x00000 through 0xFFFFF).
+const TotalPrefixes = 1 << 20
+
+// ProgressReporter receives progress notifications while prefixes are
+// downloaded. Implementations must be safe for concurrent use. Callers that
+// don't care about progress can leave it unset; Downloader falls back to a
+// no-op reporter.
+type ProgressReporter interface {
+	// Init is called once before any prefix is downloaded, with the total
+	// number of prefixes that will be processed and the number of worker
+	// slots that will call StartPrefix/CompletePrefix.
+	Init(total, workers int)
+	// StartPrefix is called when worker slot workerID begins fetching
+	// hexPrefix.
+	StartPrefix(workerID int, hexPrefix string)
+	// CompletePrefix is called once per completed prefix, whether it was
+	// downloaded, skipped (resume) or unchanged (not modified). event.Skipped
+	// is set for the latter two cases. Duration and Retries are zero for a
+	// resumed prefix, which is never fetched; an unchanged prefix carries
+	// those of its 304 request.
+	CompletePrefix(workerID int, event PrefixEvent)
+	// Done is called once after every prefix has been processed.
+	Done()
+}
+
+// PrefixEvent describes the outcome of fetching a single hash prefix.
+type PrefixEvent struct {
+	HexPrefix   string
+	CacheStatus string
+	Duration    time.Duration
+	Retries     int
+	Skipped     bool
+}
+
+type noopProgress struct{}
+
+func (noopProgress) Init(int, int)                   {}
+func (noopProgress) StartPrefix(int, string)         {}
+func (noopProgress) CompletePrefix(int, PrefixEvent) {}
+func (noopProgress) Done()                           {}
+
+// Options configures a Downloader.
+type Options struct {
+	// Parallelism is the number of concurrent range requests. Must be
+	// greater than zero.
+	Parallelism int
+	// Resume skips prefixes the Writer reports as already written.
+	Resume bool
+	// Refresh forces a full re-download, ignoring any ETag recorded in
+	// Manifest.
+	Refresh bool
+}
+
+// Downloader fetches every hash-prefix range through Client and hands each
+// response to Output. It is safe to use from other Go programs: supply your
+// own *http.Client via client.New, your own output.Writer, and optionally a
+// ProgressReporter to drive a custom UI. Client accepts any client.Fetcher,
+// so a caching decorator can be layered in front of the base client.Client.
+type Downloader struct {
+	Client   client.Fetcher
+	Output   output.Writer
+	Stats    *stats.Stats
+	Progress ProgressReporter
+	Options  Options
+	// Manifest, if set, is consulted for a per-prefix ETag to send as
+	// If-None-Match, and updated with the outcome of each request. It is
+	// saved once after all prefixes have completed. Leave nil to always
+	// fetch every prefix in full, as the individual-files mode did before
+	// incremental updates were added.
+	Manifest *manifest.Manifest
+}
+
+// New returns a Downloader ready to run.
+func New(c client.Fetcher, w output.Writer, s *stats.Stats, opts Options) *Downloader {
+	return &Downloader{
+		Client:  c,
+		Output:  w,
+		Stats:   s,
+		Options: opts,
+	}
+}
+
+// Run downloads every hash-prefix range and writes it through Output,
+// calling Output.Finalize once all prefixes have completed. It returns the
+// first error encountered by any worker, or the error from Finalize.
+// Run respects ctx cancellation: once ctx is done, in-flight prefixes still
+// finish but no new ones are started.
+func (d *Downloader) Run(ctx context.Context) error {
+	progress := d.Progress
+	if progress == nil {
+		progress = noopProgress{}
+	}
+	progress.Init(TotalPrefixes, d.Options.Parallelism)
+
+	slots := make(chan int, d.Options.Parallelism)
+	for i := 0; i < d.Options.Parallelism; i++ {
+		slots <- i
+	}
+
+	pool := pond.New(d.Options.Parallelism, TotalPrefixes)
+
+	var mu sync.Mutex
+	var firstErr error
+	recordErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for i := 0; i < TotalPrefixes; i++ {
+		prefix := i
+		pool.Submit(func() {
+			if ctx.Err() != nil {
+				return
+			}
+
+			workerID := <-slots
+			defer func() { slots <- workerID }()
+
+			hexPrefix := intToHex(prefix)
+			progress.StartPrefix(workerID, hexPrefix)
+
+			event, err := d.downloadPrefix(ctx, hexPrefix)
+			if err != nil {
+				recordErr(err)
+				return
+			}
+			progress.CompletePrefix(workerID, event)
+		})
+	}
+	pool.StopAndWait()
+	progress.Done()
+
+	if d.Manifest != nil {
+		if err := d.Manifest.Save(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return d.Output.Finalize()
+}
+
+func (d *Downloader) downloadPrefix(ctx context.Context, hexPrefix string) (PrefixEvent, error) {
+	if d.Options.Resume && d.Output.Exists(hexPrefix) {
+		return PrefixEvent{HexPrefix: hexPrefix, Skipped: true}, nil
+	}
+
+	etag := ""
+	if d.Manifest != nil && !d.Options.Refresh {
+		if entry, ok := d.Manifest.Get(hexPrefix); ok {
+			etag = entry.ETag
+		}
+	}
+
+	resp, err := d.Client.Fetch(ctx, hexPrefix, etag)
+	if err != nil {
+		return PrefixEvent{}, err
+	}
+	if resp.CacheStatus == "DISK" {
+		d.Stats.RecordDiskCacheHit()
+	} else {
+		d.Stats.RecordRequest(resp.CacheStatus, resp.Duration)
+	}
+
+	event := PrefixEvent{
+		HexPrefix:   hexPrefix,
+		CacheStatus: resp.CacheStatus,
+		Duration:    resp.Duration,
+		Retries:     resp.Retries,
+	}
+
+	if resp.NotModified {
+		d.Stats.AddNotModified(1)
+		event.Skipped = true
+		return event, nil
+	}
+
+	if err := d.Output.Write(hexPrefix, resp.Body); err != nil {
+		return PrefixEvent{}, err
+	}
+	d.Stats.AddHashesDownloaded(uint64(bytes.Count(resp.Body, []byte("\n")) + 1))
+
+	if d.Manifest != nil {
+		sum := sha256.Sum256(resp.Body)
+		d.Manifest.Set(hexPrefix, manifest.Entry{
+			ETag:         resp.ETag,
+			SHA256:       hex.EncodeToString(sum[:]),
+			Size:         int64(len(resp.Body)),
+			LastModified: time.Now(),
+		})
+	}
+
+	return event, nil
+}
+
+func intToHex(i int) string {
+	return fmt.Sprintf("%05X", i)
+}
@@ -0,0 +1,251 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"hibp_pw_downloader.rasc.ch/hibp/client"
+	"hibp_pw_downloader.rasc.ch/hibp/manifest"
+	"hibp_pw_downloader.rasc.ch/hibp/stats"
+)
+
+// fakeFetcher is a client.Fetcher stub that returns a canned Response (or
+// error) per call and records the etag it was sent, so tests can assert on
+// what downloadPrefix asked for.
+type fakeFetcher struct {
+	mu       sync.Mutex
+	resp     *client.Response
+	err      error
+	lastETag string
+	calls    int
+}
+
+func (f *fakeFetcher) Fetch(ctx context.Context, hexPrefix, etag string) (*client.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	f.lastETag = etag
+	if f.err != nil {
+		return nil, f.err
+	}
+	resp := *f.resp
+	resp.HexPrefix = hexPrefix
+	return &resp, nil
+}
+
+// fakeWriter is an output.Writer stub that records every Write call and
+// reports Exists for a fixed set of prefixes.
+type fakeWriter struct {
+	mu      sync.Mutex
+	exists  map[string]bool
+	written map[string][]byte
+}
+
+func newFakeWriter() *fakeWriter {
+	return &fakeWriter{exists: map[string]bool{}, written: map[string][]byte{}}
+}
+
+func (w *fakeWriter) Exists(hexPrefix string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.exists[hexPrefix]
+}
+
+func (w *fakeWriter) Write(hexPrefix string, body []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written[hexPrefix] = body
+	return nil
+}
+
+func (w *fakeWriter) Finalize() error {
+	return nil
+}
+
+func TestDownloadPrefixSkipsExistingWhenResuming(t *testing.T) {
+	fetcher := &fakeFetcher{resp: &client.Response{Body: []byte("AAA:1\n")}}
+	writer := newFakeWriter()
+	writer.exists["00000"] = true
+
+	d := &Downloader{
+		Client:  fetcher,
+		Output:  writer,
+		Stats:   stats.New(),
+		Options: Options{Resume: true},
+	}
+
+	event, err := d.downloadPrefix(context.Background(), "00000")
+	if err != nil {
+		t.Fatalf("downloadPrefix: %v", err)
+	}
+	if !event.Skipped {
+		t.Error("event.Skipped = false, want true")
+	}
+	if fetcher.calls != 0 {
+		t.Errorf("fetcher.calls = %d, want 0 (resume should skip the fetch entirely)", fetcher.calls)
+	}
+}
+
+func TestDownloadPrefixSendsManifestETagUnlessRefreshing(t *testing.T) {
+	fetcher := &fakeFetcher{resp: &client.Response{Body: []byte("AAA:1\n")}}
+	writer := newFakeWriter()
+	m, err := manifest.Load(t.TempDir() + "/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest.Load: %v", err)
+	}
+	m.Set("00000", manifest.Entry{ETag: `"cached-etag"`})
+
+	d := &Downloader{
+		Client:   fetcher,
+		Output:   writer,
+		Stats:    stats.New(),
+		Manifest: m,
+	}
+
+	if _, err := d.downloadPrefix(context.Background(), "00000"); err != nil {
+		t.Fatalf("downloadPrefix: %v", err)
+	}
+	if fetcher.lastETag != `"cached-etag"` {
+		t.Errorf("etag sent = %q, want %q", fetcher.lastETag, `"cached-etag"`)
+	}
+
+	d.Options.Refresh = true
+	if _, err := d.downloadPrefix(context.Background(), "00000"); err != nil {
+		t.Fatalf("downloadPrefix: %v", err)
+	}
+	if fetcher.lastETag != "" {
+		t.Errorf("etag sent with Refresh = %q, want empty", fetcher.lastETag)
+	}
+}
+
+func TestDownloadPrefixNotModifiedSkipsWriteAndCountsIt(t *testing.T) {
+	fetcher := &fakeFetcher{resp: &client.Response{NotModified: true, CacheStatus: "HIT"}}
+	writer := newFakeWriter()
+	s := stats.New()
+
+	d := &Downloader{Client: fetcher, Output: writer, Stats: s}
+
+	event, err := d.downloadPrefix(context.Background(), "00000")
+	if err != nil {
+		t.Fatalf("downloadPrefix: %v", err)
+	}
+	if !event.Skipped {
+		t.Error("event.Skipped = false, want true")
+	}
+	if len(writer.written) != 0 {
+		t.Errorf("writer.written = %v, want empty (not-modified prefixes must not be written)", writer.written)
+	}
+	if got := s.CloudflareNotModified.Load(); got != 1 {
+		t.Errorf("CloudflareNotModified = %d, want 1", got)
+	}
+}
+
+func TestDownloadPrefixWritesBodyAndUpdatesManifest(t *testing.T) {
+	body := []byte("AAA:1\nBBB:2\n")
+	fetcher := &fakeFetcher{resp: &client.Response{Body: body, ETag: `"new-etag"`, CacheStatus: "MISS"}}
+	writer := newFakeWriter()
+	m, err := manifest.Load(t.TempDir() + "/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest.Load: %v", err)
+	}
+	s := stats.New()
+
+	d := &Downloader{Client: fetcher, Output: writer, Stats: s, Manifest: m}
+
+	if _, err := d.downloadPrefix(context.Background(), "00000"); err != nil {
+		t.Fatalf("downloadPrefix: %v", err)
+	}
+
+	if string(writer.written["00000"]) != string(body) {
+		t.Errorf("written body = %q, want %q", writer.written["00000"], body)
+	}
+	if got := s.HashesDownloaded.Load(); got != 3 {
+		t.Errorf("HashesDownloaded = %d, want 3", got)
+	}
+	entry, ok := m.Get("00000")
+	if !ok {
+		t.Fatal("manifest entry not recorded")
+	}
+	if entry.ETag != `"new-etag"` {
+		t.Errorf("manifest ETag = %q, want %q", entry.ETag, `"new-etag"`)
+	}
+}
+
+func TestDownloadPrefixRoutesDiskCacheHitsSeparately(t *testing.T) {
+	fetcher := &fakeFetcher{resp: &client.Response{Body: []byte("AAA:1\n"), CacheStatus: "DISK"}}
+	writer := newFakeWriter()
+	s := stats.New()
+
+	d := &Downloader{Client: fetcher, Output: writer, Stats: s}
+
+	if _, err := d.downloadPrefix(context.Background(), "00000"); err != nil {
+		t.Fatalf("downloadPrefix: %v", err)
+	}
+	if got := s.DiskCacheHits.Load(); got != 1 {
+		t.Errorf("DiskCacheHits = %d, want 1", got)
+	}
+	if got := s.CloudflareRequests.Load(); got != 0 {
+		t.Errorf("CloudflareRequests = %d, want 0 (disk cache hits must not count as Cloudflare requests)", got)
+	}
+}
+
+func TestDownloadPrefixReturnsFetchError(t *testing.T) {
+	wantErr := errors.New("boom")
+	fetcher := &fakeFetcher{err: wantErr}
+	writer := newFakeWriter()
+
+	d := &Downloader{Client: fetcher, Output: writer, Stats: stats.New()}
+
+	if _, err := d.downloadPrefix(context.Background(), "00000"); !errors.Is(err, wantErr) {
+		t.Errorf("downloadPrefix error = %v, want %v", err, wantErr)
+	}
+}
+
+func TestRunWritesEveryPrefix(t *testing.T) {
+	fetcher := &fakeFetcher{resp: &client.Response{Body: []byte("AAA:1\n"), CacheStatus: "HIT"}}
+	writer := newFakeWriter()
+
+	d := &Downloader{
+		Client:  fetcher,
+		Output:  writer,
+		Stats:   stats.New(),
+		Options: Options{Parallelism: 64},
+	}
+
+	start := time.Now()
+	if err := d.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Errorf("Run took %s, want well under 30s for an in-memory fetcher/writer", elapsed)
+	}
+
+	if len(writer.written) != TotalPrefixes {
+		t.Errorf("wrote %d prefixes, want %d", len(writer.written), TotalPrefixes)
+	}
+}
+
+func TestRunStopsSubmittingAfterContextCancelled(t *testing.T) {
+	fetcher := &fakeFetcher{resp: &client.Response{Body: []byte("AAA:1\n"), CacheStatus: "HIT"}}
+	writer := newFakeWriter()
+
+	d := &Downloader{
+		Client:  fetcher,
+		Output:  writer,
+		Stats:   stats.New(),
+		Options: Options{Parallelism: 4},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := d.Run(ctx); err == nil {
+		t.Error("Run() with an already-cancelled context = nil error, want context.Canceled")
+	}
+	if len(writer.written) == TotalPrefixes {
+		t.Error("Run() wrote every prefix despite an already-cancelled context")
+	}
+}
@@ -0,0 +1,123 @@
+// Package manifest tracks, per hash prefix, the HIBP ETag and content hash
+// of the last successfully downloaded range. It lets a Downloader send
+// If-None-Match on subsequent runs and skip re-downloading ranges that
+// HIBP reports as unchanged (304 Not Modified), and lets callers detect
+// local corruption by re-hashing files against the recorded digest.
+//
+// The manifest only makes sense for the individual-files output mode
+// (output.PerPrefixWriter), since that is the only mode where per-prefix
+// files persist on disk between runs.
+package manifest
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"hibp_pw_downloader.rasc.ch/hibp/output"
+)
+
+// Entry records what was last downloaded for a single hash prefix.
+type Entry struct {
+	ETag         string    `json:"etag"`
+	SHA256       string    `json:"sha256"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// Manifest maps a hex hash prefix to the Entry last recorded for it. It is
+// safe for concurrent use.
+type Manifest struct {
+	mu      sync.Mutex
+	path    string
+	Entries map[string]Entry `json:"entries"`
+}
+
+// Load reads the manifest stored at path. A missing file is not an error:
+// it yields an empty manifest that Save will create at path.
+func Load(path string) (*Manifest, error) {
+	m := &Manifest{path: path, Entries: map[string]Entry{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Entries == nil {
+		m.Entries = map[string]Entry{}
+	}
+	return m, nil
+}
+
+// Get returns the recorded entry for hexPrefix, if any.
+func (m *Manifest) Get(hexPrefix string) (Entry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.Entries[hexPrefix]
+	return e, ok
+}
+
+// Set records e as the latest entry for hexPrefix.
+func (m *Manifest) Set(hexPrefix string, e Entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Entries[hexPrefix] = e
+}
+
+// Save writes the manifest back to the path it was loaded from.
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.path, data, 0o644)
+}
+
+// Result summarises a Verify run.
+type Result struct {
+	Checked   int
+	Missing   []string
+	Corrupted []string
+}
+
+// Verify re-hashes every prefix recorded in the manifest against the file
+// w has on disk for it, reporting prefixes that are missing locally or
+// whose content no longer matches the recorded SHA-256.
+func (m *Manifest) Verify(w *output.PerPrefixWriter) (Result, error) {
+	m.mu.Lock()
+	prefixes := make([]string, 0, len(m.Entries))
+	for hexPrefix := range m.Entries {
+		prefixes = append(prefixes, hexPrefix)
+	}
+	entries := m.Entries
+	m.mu.Unlock()
+	sort.Strings(prefixes)
+
+	var result Result
+	for _, hexPrefix := range prefixes {
+		result.Checked++
+		if !w.Exists(hexPrefix) {
+			result.Missing = append(result.Missing, hexPrefix)
+			continue
+		}
+
+		sum, err := w.SHA256(hexPrefix)
+		if err != nil {
+			return result, err
+		}
+		if sum != entries[hexPrefix].SHA256 {
+			result.Corrupted = append(result.Corrupted, hexPrefix)
+		}
+	}
+
+	return result, nil
+}
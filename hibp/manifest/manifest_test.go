@@ -0,0 +1,94 @@
+package manifest
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"hibp_pw_downloader.rasc.ch/hibp/output"
+)
+
+func TestLoadMissingFileYieldsEmptyManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "does-not-exist.json")
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(m.Entries) != 0 {
+		t.Errorf("Entries = %v, want empty", m.Entries)
+	}
+	if _, ok := m.Get("00000"); ok {
+		t.Error("Get() on an empty manifest reported an entry, want none")
+	}
+}
+
+func TestSetGetSaveLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	entry := Entry{ETag: `"abc123"`, SHA256: "deadbeef", Size: 42, LastModified: time.Now().UTC().Truncate(time.Second)}
+	m.Set("00000", entry)
+
+	if got, ok := m.Get("00000"); !ok || got != entry {
+		t.Fatalf("Get(00000) = %+v, %v, want %+v, true", got, ok, entry)
+	}
+
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load after Save: %v", err)
+	}
+	got, ok := reloaded.Get("00000")
+	if !ok {
+		t.Fatal("Get(00000) after reload reported no entry")
+	}
+	if !got.LastModified.Equal(entry.LastModified) || got.ETag != entry.ETag || got.SHA256 != entry.SHA256 || got.Size != entry.Size {
+		t.Errorf("reloaded entry = %+v, want %+v", got, entry)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	folder := t.TempDir()
+	w := output.NewPerPrefixWriter(folder)
+
+	if err := w.Write("00000", []byte("0000000000000000000A:5")); err != nil {
+		t.Fatalf("Write(00000): %v", err)
+	}
+	if err := w.Write("00001", []byte("1111111111111111111B:2")); err != nil {
+		t.Fatalf("Write(00001): %v", err)
+	}
+
+	sum00000, err := w.SHA256("00000")
+	if err != nil {
+		t.Fatalf("SHA256(00000): %v", err)
+	}
+
+	m, err := Load(filepath.Join(folder, ".manifest.json"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	m.Set("00000", Entry{SHA256: sum00000})
+	m.Set("00001", Entry{SHA256: "not-the-real-hash"})
+	m.Set("00002", Entry{SHA256: "irrelevant"}) // never written to disk
+
+	result, err := m.Verify(w)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	if result.Checked != 3 {
+		t.Errorf("Checked = %d, want 3", result.Checked)
+	}
+	if len(result.Missing) != 1 || result.Missing[0] != "00002" {
+		t.Errorf("Missing = %v, want [00002]", result.Missing)
+	}
+	if len(result.Corrupted) != 1 || result.Corrupted[0] != "00001" {
+		t.Errorf("Corrupted = %v, want [00001]", result.Corrupted)
+	}
+}
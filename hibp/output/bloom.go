@@ -0,0 +1,135 @@
+package output
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// bloomMagic identifies a BloomWriter output file.
+const bloomMagic = "HIBPBLM1"
+
+// DefaultBloomN is the expected number of items BloomWriter sizes itself
+// for when the caller doesn't know the exact count, based on the size of
+// the HIBP Pwned Passwords corpus.
+const DefaultBloomN = 850_000_000
+
+// DefaultBloomFPR is the false-positive rate BloomWriter targets when none
+// is given.
+const DefaultBloomFPR = 1e-6
+
+// BloomWriter builds a Bloom filter of every downloaded hash and writes it
+// to OutputFile on Finalize, instead of the per-prefix .txt files the other
+// output modes produce. It lets a consumer answer "is this password pwned?"
+// against a filter many times smaller than the full corpus, at the cost of
+// a small, tunable false-positive rate.
+type BloomWriter struct {
+	OutputFile string
+
+	n   uint64
+	m   uint64
+	k   uint64
+	fpr float64
+
+	bits []atomic.Uint64
+}
+
+// NewBloomWriter returns a BloomWriter sized for n items at false-positive
+// rate fpr, writing the finished filter to outputFile on Finalize. The
+// optimal bit count m and hash count k are computed from n and fpr:
+// m = -n*ln(fpr)/(ln2)^2 and k = (m/n)*ln2. It returns an error if n is zero
+// or fpr is not a probability in (0, 1), since either would size the filter
+// to zero bits and panic on the first insert.
+func NewBloomWriter(outputFile string, n uint64, fpr float64) (*BloomWriter, error) {
+	if n == 0 {
+		return nil, fmt.Errorf("bloom filter item count must be greater than zero")
+	}
+	if math.IsNaN(fpr) || math.IsInf(fpr, 0) || fpr <= 0 || fpr >= 1 {
+		return nil, fmt.Errorf("bloom filter false-positive rate must be between 0 and 1, got %v", fpr)
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpr) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &BloomWriter{
+		OutputFile: outputFile,
+		n:          n,
+		m:          m,
+		k:          k,
+		fpr:        fpr,
+		bits:       make([]atomic.Uint64, (m+63)/64),
+	}, nil
+}
+
+// Exists always reports false: a Bloom filter can't tell which prefixes
+// have already been inserted, so BloomWriter does not support --resume.
+func (w *BloomWriter) Exists(hexPrefix string) bool {
+	return false
+}
+
+// Write implements Writer by inserting every hash in body into the filter.
+func (w *BloomWriter) Write(hexPrefix string, body []byte) error {
+	for _, line := range strings.Split(string(body), "\n") {
+		if line == "" {
+			continue
+		}
+		suffix, _, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		sum, err := hex.DecodeString(hexPrefix + suffix)
+		if err != nil || len(sum) < 16 {
+			continue
+		}
+		w.insert(sum)
+	}
+	return nil
+}
+
+// insert sets the k bits sum maps to, using double hashing so no hashing
+// beyond the SHA-1/NTLM HIBP already computed is needed: the first 8 bytes
+// of sum are h1, the next 8 are h2, and h_i(x) = h1 + i*h2 mod m.
+func (w *BloomWriter) insert(sum []byte) {
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16])
+	for i := uint64(0); i < w.k; i++ {
+		pos := (h1 + i*h2) % w.m
+		w.bits[pos/64].Or(1 << (pos % 64))
+	}
+}
+
+// Finalize writes the filter header (magic, n, m, k, fpr) followed by the
+// bit array to OutputFile.
+func (w *BloomWriter) Finalize() error {
+	f, err := os.Create(w.OutputFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header := make([]byte, 0, len(bloomMagic)+8+8+8+8)
+	header = append(header, bloomMagic...)
+	header = binary.BigEndian.AppendUint64(header, w.n)
+	header = binary.BigEndian.AppendUint64(header, w.m)
+	header = binary.BigEndian.AppendUint64(header, w.k)
+	header = binary.BigEndian.AppendUint64(header, math.Float64bits(w.fpr))
+	if _, err := f.Write(header); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 8)
+	for i := range w.bits {
+		binary.BigEndian.PutUint64(buf, w.bits[i].Load())
+		if _, err := f.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package output
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewBloomWriterValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		n    uint64
+		fpr  float64
+	}{
+		{"zero n", 0, DefaultBloomFPR},
+		{"zero fpr", 100, 0},
+		{"fpr equal to one", 100, 1},
+		{"negative fpr", 100, -0.1},
+		{"NaN fpr", 100, math.NaN()},
+		{"Inf fpr", 100, math.Inf(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewBloomWriter(filepath.Join(t.TempDir(), "out.bloom"), tt.n, tt.fpr); err == nil {
+				t.Fatalf("NewBloomWriter(n=%d, fpr=%v) = nil error, want error", tt.n, tt.fpr)
+			}
+		})
+	}
+}
+
+func TestBloomWriterWriteAndFinalize(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "out.bloom")
+	w, err := NewBloomWriter(outputFile, 1000, 1e-3)
+	if err != nil {
+		t.Fatalf("NewBloomWriter: %v", err)
+	}
+
+	// A full SHA-1 is 40 hex chars; hexPrefix supplies the first 5, so the
+	// suffix HIBP would send on the wire is the remaining 35.
+	suffix := strings.Repeat("a", 35)
+	if err := w.Write("00000", []byte(fmt.Sprintf("%s:5", suffix))); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Finalize(); err != nil {
+		t.Fatalf("Finalize: %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	wantHeaderLen := len(bloomMagic) + 8 + 8 + 8 + 8
+	if len(data) < wantHeaderLen {
+		t.Fatalf("output file too short: got %d bytes, want at least %d", len(data), wantHeaderLen)
+	}
+	if magic := string(data[:len(bloomMagic)]); magic != bloomMagic {
+		t.Errorf("magic = %q, want %q", magic, bloomMagic)
+	}
+
+	off := len(bloomMagic)
+	n := binary.BigEndian.Uint64(data[off:])
+	off += 8
+	m := binary.BigEndian.Uint64(data[off:])
+	off += 8
+	k := binary.BigEndian.Uint64(data[off:])
+	off += 8
+	fpr := math.Float64frombits(binary.BigEndian.Uint64(data[off:]))
+
+	if n != w.n || n != 1000 {
+		t.Errorf("n = %d, want %d", n, w.n)
+	}
+	if m != w.m || k != w.k || fpr != w.fpr {
+		t.Errorf("header (m=%d, k=%d, fpr=%v) does not match writer (m=%d, k=%d, fpr=%v)", m, k, fpr, w.m, w.k, w.fpr)
+	}
+
+	bits := data[off+8:]
+	wantBitsLen := int((w.m + 63) / 64 * 8)
+	if len(bits) != wantBitsLen {
+		t.Fatalf("bit array is %d bytes, want %d", len(bits), wantBitsLen)
+	}
+
+	allZero := true
+	for _, b := range bits {
+		if b != 0 {
+			allZero = false
+			break
+		}
+	}
+	if allZero {
+		t.Error("bit array is all zero after inserting a hash, want at least one set bit")
+	}
+}
+
+func TestBloomWriterExistsAlwaysFalse(t *testing.T) {
+	w, err := NewBloomWriter(filepath.Join(t.TempDir(), "out.bloom"), 100, DefaultBloomFPR)
+	if err != nil {
+		t.Fatalf("NewBloomWriter: %v", err)
+	}
+	if w.Exists("00000") {
+		t.Error("Exists() = true, want false: a Bloom filter cannot support --resume")
+	}
+}
+
+func TestBloomWriterInsertIsDeterministic(t *testing.T) {
+	w1, err := NewBloomWriter(filepath.Join(t.TempDir(), "a.bloom"), 100, DefaultBloomFPR)
+	if err != nil {
+		t.Fatalf("NewBloomWriter: %v", err)
+	}
+	w2, err := NewBloomWriter(filepath.Join(t.TempDir(), "b.bloom"), 100, DefaultBloomFPR)
+	if err != nil {
+		t.Fatalf("NewBloomWriter: %v", err)
+	}
+
+	sum := make([]byte, 20)
+	for i := range sum {
+		sum[i] = byte(i * 7)
+	}
+	w1.insert(sum)
+	w2.insert(sum)
+
+	for i := range w1.bits {
+		if w1.bits[i].Load() != w2.bits[i].Load() {
+			t.Fatalf("word %d differs: %d vs %d, insert should be a pure function of sum", i, w1.bits[i].Load(), w2.bits[i].Load())
+		}
+	}
+}
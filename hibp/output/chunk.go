@@ -0,0 +1,351 @@
+package output
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultChunkSize is the number of hash prefixes packed into a single
+// chunk archive when ChunkWriter is constructed with chunkSize <= 0.
+const DefaultChunkSize = 4096
+
+// ChunkManifest is the recovery manifest written alongside every chunk
+// archive, named "<PoolID>.manifest.json".
+type ChunkManifest struct {
+	PoolID    string    `json:"poolID"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	ItemCount int       `json:"itemCount"`
+	Prefixes  []string  `json:"prefixes"`
+	Created   time.Time `json:"created"`
+}
+
+// ChunkWriter groups consecutive hash prefixes into fixed-size chunks and
+// writes each chunk as a tar archive of per-prefix entries, zstd-compressed,
+// together with a ChunkManifest. It implements Writer.
+//
+// Because prefixes can arrive out of order from concurrent workers,
+// ChunkWriter buffers a chunk's members in memory until all of them have
+// been written, then flushes the chunk to disk.
+type ChunkWriter struct {
+	Folder    string
+	ChunkSize int
+
+	mu      sync.Mutex
+	pending map[int]*chunkAccumulator
+}
+
+type chunkAccumulator struct {
+	prefixes []string
+	bodies   map[string][]byte
+}
+
+// NewChunkWriter returns a Writer that packs chunkSize consecutive prefixes
+// into each chunk archive inside folder. The folder must already exist. A
+// chunkSize <= 0 uses DefaultChunkSize.
+func NewChunkWriter(folder string, chunkSize int) *ChunkWriter {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return &ChunkWriter{
+		Folder:    folder,
+		ChunkSize: chunkSize,
+		pending:   map[int]*chunkAccumulator{},
+	}
+}
+
+func (w *ChunkWriter) chunkIndex(hexPrefix string) (int, error) {
+	prefix, err := strconv.ParseInt(hexPrefix, 16, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int(prefix) / w.ChunkSize, nil
+}
+
+// poolID is the chunk's identifier: the hex prefix of its first member.
+func (w *ChunkWriter) poolID(chunkIndex int) string {
+	return fmt.Sprintf("%05X", chunkIndex*w.ChunkSize)
+}
+
+func (w *ChunkWriter) chunkPath(poolID string) string {
+	return filepath.Join(w.Folder, poolID+".chunk.zst")
+}
+
+func (w *ChunkWriter) manifestPath(poolID string) string {
+	return filepath.Join(w.Folder, poolID+".manifest.json")
+}
+
+// Exists implements Writer. A chunk is all-or-nothing, so it reports
+// whether the chunk hexPrefix belongs to has already been flushed.
+func (w *ChunkWriter) Exists(hexPrefix string) bool {
+	idx, err := w.chunkIndex(hexPrefix)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(w.chunkPath(w.poolID(idx)))
+	return !os.IsNotExist(err)
+}
+
+// Write implements Writer, buffering body until every prefix in its chunk
+// has arrived, then flushing the chunk archive.
+func (w *ChunkWriter) Write(hexPrefix string, body []byte) error {
+	idx, err := w.chunkIndex(hexPrefix)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	acc, ok := w.pending[idx]
+	if !ok {
+		acc = &chunkAccumulator{bodies: map[string][]byte{}}
+		w.pending[idx] = acc
+	}
+	acc.prefixes = append(acc.prefixes, hexPrefix)
+	acc.bodies[hexPrefix] = body
+	complete := len(acc.prefixes) >= w.ChunkSize
+	if complete {
+		delete(w.pending, idx)
+	}
+	w.mu.Unlock()
+
+	if !complete {
+		return nil
+	}
+	return w.flush(idx, acc)
+}
+
+func (w *ChunkWriter) flush(idx int, acc *chunkAccumulator) error {
+	sort.Strings(acc.prefixes)
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	for _, hexPrefix := range acc.prefixes {
+		content := encodeLines(hexPrefix, acc.bodies[hexPrefix])
+		if err := tw.WriteHeader(&tar.Header{
+			Name: hexPrefix + ".txt",
+			Mode: 0o644,
+			Size: int64(len(content)),
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return err
+	}
+	compressed := enc.EncodeAll(tarBuf.Bytes(), nil)
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	poolID := w.poolID(idx)
+	if err := os.WriteFile(w.chunkPath(poolID), compressed, 0o644); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(compressed)
+	manifest := ChunkManifest{
+		PoolID:    poolID,
+		Size:      int64(len(compressed)),
+		SHA256:    hex.EncodeToString(sum[:]),
+		ItemCount: len(acc.prefixes),
+		Prefixes:  acc.prefixes,
+		Created:   time.Now(),
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(w.manifestPath(poolID), data, 0o644)
+}
+
+// Finalize implements Writer, flushing any chunk that never reached
+// ChunkSize members because it is the last chunk of an uneven split.
+func (w *ChunkWriter) Finalize() error {
+	w.mu.Lock()
+	remaining := w.pending
+	w.pending = map[int]*chunkAccumulator{}
+	w.mu.Unlock()
+
+	for idx, acc := range remaining {
+		if err := w.flush(idx, acc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChunkReader streams the (prefix, hash, count) tuples stored in a chunk
+// archive after verifying its SHA-256 against the recorded ChunkManifest.
+type ChunkReader struct {
+	manifest ChunkManifest
+	tr       *tar.Reader
+
+	prefix string
+	lines  []string
+	line   int
+}
+
+// OpenChunk reads and verifies the chunk archive identified by poolID
+// inside folder, returning a ChunkReader positioned at its first entry.
+func OpenChunk(folder, poolID string) (*ChunkReader, error) {
+	manifestData, err := os.ReadFile(filepath.Join(folder, poolID+".manifest.json"))
+	if err != nil {
+		return nil, err
+	}
+	var manifest ChunkManifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, err
+	}
+
+	compressed, err := os.ReadFile(filepath.Join(folder, poolID+".chunk.zst"))
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(compressed)
+	if got := hex.EncodeToString(sum[:]); got != manifest.SHA256 {
+		return nil, fmt.Errorf("chunk %s: sha256 mismatch: manifest has %s, archive is %s", poolID, manifest.SHA256, got)
+	}
+
+	dec, err := zstd.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+
+	decoded, err := io.ReadAll(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChunkReader{manifest: manifest, tr: tar.NewReader(bytes.NewReader(decoded))}, nil
+}
+
+// Manifest returns the ChunkManifest recorded for this chunk.
+func (r *ChunkReader) Manifest() ChunkManifest {
+	return r.manifest
+}
+
+// HashCount is one decoded line from a chunk: a full hash and the number
+// of times HIBP has seen it.
+type HashCount struct {
+	Prefix string
+	Hash   string
+	Count  int
+}
+
+// Next returns the next HashCount in the chunk. It returns io.EOF once
+// every entry has been read.
+func (r *ChunkReader) Next() (HashCount, error) {
+	for {
+		if r.lines != nil && r.line < len(r.lines) {
+			line := r.lines[r.line]
+			r.line++
+			if line == "" {
+				continue
+			}
+			return parseHashCountLine(r.prefix, line)
+		}
+
+		hdr, err := r.tr.Next()
+		if err != nil {
+			return HashCount{}, err
+		}
+		r.prefix = strings.TrimSuffix(hdr.Name, ".txt")
+		data, err := io.ReadAll(r.tr)
+		if err != nil {
+			return HashCount{}, err
+		}
+		r.lines = strings.Split(string(data), "\n")
+		r.line = 0
+	}
+}
+
+func parseHashCountLine(prefix, line string) (HashCount, error) {
+	rest := strings.TrimPrefix(line, prefix)
+	suffix, countStr, ok := strings.Cut(rest, ":")
+	if !ok {
+		return HashCount{}, fmt.Errorf("malformed chunk line %q", line)
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil {
+		return HashCount{}, fmt.Errorf("malformed chunk line %q: %w", line, err)
+	}
+	return HashCount{Prefix: prefix, Hash: prefix + suffix, Count: count}, nil
+}
+
+// ChunkVerifyResult summarises a VerifyChunks run.
+type ChunkVerifyResult struct {
+	Checked   int
+	Missing   []string
+	Corrupted []string
+}
+
+// VerifyChunks re-hashes every chunk archive in folder against its
+// manifest's recorded SHA-256.
+func VerifyChunks(folder string) (ChunkVerifyResult, error) {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return ChunkVerifyResult{}, err
+	}
+
+	var poolIDs []string
+	for _, e := range entries {
+		if poolID, ok := strings.CutSuffix(e.Name(), ".manifest.json"); ok {
+			poolIDs = append(poolIDs, poolID)
+		}
+	}
+	sort.Strings(poolIDs)
+
+	var result ChunkVerifyResult
+	for _, poolID := range poolIDs {
+		result.Checked++
+
+		manifestData, err := os.ReadFile(filepath.Join(folder, poolID+".manifest.json"))
+		if err != nil {
+			return result, err
+		}
+		var manifest ChunkManifest
+		if err := json.Unmarshal(manifestData, &manifest); err != nil {
+			return result, err
+		}
+
+		compressed, err := os.ReadFile(filepath.Join(folder, poolID+".chunk.zst"))
+		if os.IsNotExist(err) {
+			result.Missing = append(result.Missing, poolID)
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+
+		sum := sha256.Sum256(compressed)
+		if hex.EncodeToString(sum[:]) != manifest.SHA256 {
+			result.Corrupted = append(result.Corrupted, poolID)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,128 @@
+package output
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChunkWriterRoundTrip(t *testing.T) {
+	folder := t.TempDir()
+	w := NewChunkWriter(folder, 2)
+
+	if w.Exists("00000") {
+		t.Error("Exists() = true before any write, want false")
+	}
+
+	if err := w.Write("00000", []byte("0000000000000000000A:5")); err != nil {
+		t.Fatalf("Write(00000): %v", err)
+	}
+	if w.Exists("00000") {
+		t.Error("Exists() = true before the chunk it belongs to is flushed, want false")
+	}
+	if err := w.Write("00001", []byte("1111111111111111111B:2")); err != nil {
+		t.Fatalf("Write(00001): %v", err)
+	}
+	if err := w.Finalize(); err != nil {
+		t.Fatalf("Finalize: %v", err)
+	}
+
+	if !w.Exists("00000") || !w.Exists("00001") {
+		t.Error("Exists() = false after the chunk was flushed, want true")
+	}
+
+	poolID := w.poolID(0)
+	r, err := OpenChunk(folder, poolID)
+	if err != nil {
+		t.Fatalf("OpenChunk: %v", err)
+	}
+
+	manifest := r.Manifest()
+	if manifest.ItemCount != 2 {
+		t.Errorf("manifest.ItemCount = %d, want 2", manifest.ItemCount)
+	}
+	if manifest.PoolID != poolID {
+		t.Errorf("manifest.PoolID = %q, want %q", manifest.PoolID, poolID)
+	}
+
+	var got []HashCount
+	for {
+		hc, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got = append(got, hc)
+	}
+
+	want := []HashCount{
+		{Prefix: "00000", Hash: "000000000000000000000000A", Count: 5},
+		{Prefix: "00001", Hash: "000011111111111111111111B", Count: 2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestOpenChunkDetectsCorruption(t *testing.T) {
+	folder := t.TempDir()
+	w := NewChunkWriter(folder, 1)
+	if err := w.Write("00000", []byte("0000000000000000000A:5")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	poolID := w.poolID(0)
+	chunkPath := filepath.Join(folder, poolID+".chunk.zst")
+	data, err := os.ReadFile(chunkPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	data = append(data, 0xff)
+	if err := os.WriteFile(chunkPath, data, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := OpenChunk(folder, poolID); err == nil {
+		t.Error("OpenChunk() = nil error after corrupting the archive, want a sha256 mismatch error")
+	}
+}
+
+func TestVerifyChunks(t *testing.T) {
+	folder := t.TempDir()
+	w := NewChunkWriter(folder, 1)
+	if err := w.Write("00000", []byte("0000000000000000000A:5")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Write("00001", []byte("1111111111111111111B:2")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	result, err := VerifyChunks(folder)
+	if err != nil {
+		t.Fatalf("VerifyChunks: %v", err)
+	}
+	if result.Checked != 2 || len(result.Missing) != 0 || len(result.Corrupted) != 0 {
+		t.Fatalf("VerifyChunks() = %+v, want a clean run over 2 chunks", result)
+	}
+
+	if err := os.Remove(filepath.Join(folder, w.poolID(0)+".chunk.zst")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	result, err = VerifyChunks(folder)
+	if err != nil {
+		t.Fatalf("VerifyChunks: %v", err)
+	}
+	if len(result.Missing) != 1 || result.Missing[0] != w.poolID(0) {
+		t.Errorf("VerifyChunks().Missing = %v, want [%s]", result.Missing, w.poolID(0))
+	}
+}
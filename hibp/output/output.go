@@ -0,0 +1,164 @@
+// Package output writes fetched hash-prefix ranges to disk: as one file per
+// prefix, merged into a single output file, packed into compressed chunk
+// archives (see ChunkWriter), inserted into a Bloom filter (see
+// BloomWriter) or stored as sorted raw binary hashes (see SortedBinWriter).
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Writer receives the decoded body for each downloaded hash prefix and
+// turns it into files on disk. Implementations must be safe for concurrent
+// use by multiple workers, except for Finalize which is called once after
+// every prefix has been written.
+type Writer interface {
+	// Exists reports whether hexPrefix has already been written, so a
+	// resumed run can skip re-fetching it.
+	Exists(hexPrefix string) bool
+	// Write stores body, the decompressed range response for hexPrefix.
+	Write(hexPrefix string, body []byte) error
+	// Finalize runs any steps needed after all prefixes have been written,
+	// such as merging per-prefix files into a single output file.
+	Finalize() error
+}
+
+// PerPrefixWriter writes one file per hash prefix into Folder, named
+// "<hexPrefix>.txt". It is the "downloads to individual files" output mode.
+type PerPrefixWriter struct {
+	Folder string
+}
+
+// NewPerPrefixWriter returns a Writer that creates one file per prefix
+// inside folder. The folder must already exist.
+func NewPerPrefixWriter(folder string) *PerPrefixWriter {
+	return &PerPrefixWriter{Folder: folder}
+}
+
+func (w *PerPrefixWriter) path(hexPrefix string) string {
+	return filepath.Join(w.Folder, hexPrefix+".txt")
+}
+
+// Exists implements Writer.
+func (w *PerPrefixWriter) Exists(hexPrefix string) bool {
+	_, err := os.Stat(w.path(hexPrefix))
+	return !os.IsNotExist(err)
+}
+
+// Write implements Writer.
+func (w *PerPrefixWriter) Write(hexPrefix string, body []byte) error {
+	f, err := os.Create(w.path(hexPrefix))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(encodeLines(hexPrefix, body))
+	return err
+}
+
+// Finalize implements Writer. PerPrefixWriter has nothing to do after all
+// prefixes have been written.
+func (w *PerPrefixWriter) Finalize() error {
+	return nil
+}
+
+// SHA256 reads the stored file for hexPrefix and returns the hex-encoded
+// SHA-256 of the original HIBP range body, reconstructed by stripping the
+// hexPrefix Write prepends to every line. It lets a manifest detect local
+// corruption without needing to keep the raw API response around.
+func (w *PerPrefixWriter) SHA256(hexPrefix string) (string, error) {
+	data, err := os.ReadFile(w.path(hexPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	lines = lines[:len(lines)-1] // every written line ends in "\n"
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, hexPrefix)
+	}
+	body := strings.Join(lines, "\n")
+
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// SingleFileWriter writes per-prefix files into a temporary folder like
+// PerPrefixWriter, then merges them into a single sorted output file on
+// Finalize and removes the temporary folder.
+type SingleFileWriter struct {
+	*PerPrefixWriter
+	OutputFile string
+}
+
+// NewSingleFileWriter returns a Writer that stages per-prefix files in
+// tempFolder and merges them into outputFile on Finalize. tempFolder must
+// already exist.
+func NewSingleFileWriter(outputFile, tempFolder string) *SingleFileWriter {
+	return &SingleFileWriter{
+		PerPrefixWriter: NewPerPrefixWriter(tempFolder),
+		OutputFile:      outputFile,
+	}
+}
+
+// Finalize implements Writer by concatenating the staged per-prefix files,
+// in prefix order, into OutputFile, then removing the temporary folder.
+func (w *SingleFileWriter) Finalize() error {
+	files, err := os.ReadDir(w.Folder)
+	if err != nil {
+		return err
+	}
+	fileNames := make([]string, 0, len(files))
+	for _, file := range files {
+		fileNames = append(fileNames, file.Name())
+	}
+	sort.Strings(fileNames)
+
+	outputFile, err := os.Create(w.OutputFile)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	for _, fileName := range fileNames {
+		if err := appendFile(outputFile, filepath.Join(w.Folder, fileName)); err != nil {
+			return err
+		}
+	}
+
+	return os.RemoveAll(w.Folder)
+}
+
+func appendFile(dst *os.File, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// encodeLines renders body, the raw decompressed HIBP range response, as the
+// "<hexPrefix><suffix:count>\n" lines every output format stores: HIBP
+// omits the prefix from each line, so it must be reattached to recover the
+// full hash.
+func encodeLines(hexPrefix string, body []byte) []byte {
+	lines := strings.Split(string(body), "\n")
+	var buf strings.Builder
+	buf.Grow(len(body) + len(lines)*len(hexPrefix))
+	for _, line := range lines {
+		buf.WriteString(hexPrefix)
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return []byte(buf.String())
+}
@@ -0,0 +1,93 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPerPrefixWriterWriteAndExists(t *testing.T) {
+	dir := t.TempDir()
+	w := NewPerPrefixWriter(dir)
+
+	if w.Exists("00000") {
+		t.Fatal("Exists() = true before Write, want false")
+	}
+
+	if err := w.Write("00000", []byte("AAA:1\nBBB:2")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if !w.Exists("00000") {
+		t.Fatal("Exists() = false after Write, want true")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "00000.txt"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	want := "00000AAA:1\n00000BBB:2\n"
+	if string(data) != want {
+		t.Errorf("file contents = %q, want %q", data, want)
+	}
+}
+
+func TestPerPrefixWriterSHA256(t *testing.T) {
+	dir := t.TempDir()
+	w := NewPerPrefixWriter(dir)
+
+	body := "AAA:1\nBBB:2"
+	if err := w.Write("00000", []byte(body)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	got, err := w.SHA256("00000")
+	if err != nil {
+		t.Fatalf("SHA256: %v", err)
+	}
+
+	rewritten, err := NewPerPrefixWriter(dir).SHA256("00000")
+	if err != nil {
+		t.Fatalf("SHA256 (second read): %v", err)
+	}
+	if got != rewritten {
+		t.Errorf("SHA256() is not stable across reads: %q != %q", got, rewritten)
+	}
+	if got == "" {
+		t.Error("SHA256() = \"\", want a hex digest")
+	}
+}
+
+func TestSingleFileWriterFinalizeMergesInPrefixOrder(t *testing.T) {
+	tempDir := t.TempDir()
+	outputFile := filepath.Join(t.TempDir(), "out.txt")
+
+	w := NewSingleFileWriter(outputFile, tempDir)
+
+	if err := w.Write("00002", []byte("CCC:1")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Write("00001", []byte("BBB:1")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Write("00000", []byte("AAA:1")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if err := w.Finalize(); err != nil {
+		t.Fatalf("Finalize: %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	want := "00000AAA:1\n00001BBB:1\n00002CCC:1\n"
+	if string(data) != want {
+		t.Errorf("merged output = %q, want %q", data, want)
+	}
+
+	if _, err := os.Stat(tempDir); !os.IsNotExist(err) {
+		t.Errorf("temp folder %s still exists after Finalize, want it removed", tempDir)
+	}
+}
@@ -0,0 +1,116 @@
+package output
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SHA1HashSize and NTLMHashSize are the raw binary lengths SortedBinWriter
+// stores each hash as.
+const (
+	SHA1HashSize = 20
+	NTLMHashSize = 16
+)
+
+// SortedBinWriter writes every hash as a raw, fixed-width binary value
+// (SHA1HashSize or NTLMHashSize bytes, no hex encoding and no per-line
+// count) sorted ascending, so a consumer can memory-map the result and
+// binary-search it in O(log n) at less than half the size of the hex text
+// format. It stages one sorted run per prefix into Folder, like
+// PerPrefixWriter, then concatenates the runs in prefix order on Finalize.
+type SortedBinWriter struct {
+	Folder     string
+	OutputFile string
+	// HashSize is the per-hash length in bytes: SHA1HashSize unless NTLM
+	// hashes were requested.
+	HashSize int
+}
+
+// NewSortedBinWriter returns a Writer that stages per-prefix binary runs in
+// tempFolder and merges them into outputFile on Finalize. tempFolder must
+// already exist. Pass ntlm to match the hash kind the Client was
+// configured to fetch.
+func NewSortedBinWriter(outputFile, tempFolder string, ntlm bool) *SortedBinWriter {
+	hashSize := SHA1HashSize
+	if ntlm {
+		hashSize = NTLMHashSize
+	}
+	return &SortedBinWriter{
+		Folder:     tempFolder,
+		OutputFile: outputFile,
+		HashSize:   hashSize,
+	}
+}
+
+func (w *SortedBinWriter) path(hexPrefix string) string {
+	return filepath.Join(w.Folder, hexPrefix+".bin")
+}
+
+// Exists implements Writer.
+func (w *SortedBinWriter) Exists(hexPrefix string) bool {
+	_, err := os.Stat(w.path(hexPrefix))
+	return !os.IsNotExist(err)
+}
+
+// Write implements Writer by decoding each "<suffix>:<count>" line back
+// into a full hash (hexPrefix reattached) and appending its raw bytes.
+// HIBP returns each prefix's suffixes already sorted, so the run for
+// hexPrefix is written in order without any sorting here.
+func (w *SortedBinWriter) Write(hexPrefix string, body []byte) error {
+	f, err := os.Create(w.path(hexPrefix))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, line := range strings.Split(string(body), "\n") {
+		if line == "" {
+			continue
+		}
+		suffix, _, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		sum, err := hex.DecodeString(hexPrefix + suffix)
+		if err != nil || len(sum) < w.HashSize {
+			continue
+		}
+		if _, err := f.Write(sum[:w.HashSize]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finalize merges the per-prefix runs into OutputFile. Because the runs
+// are already sorted and cover disjoint, strictly increasing prefix
+// ranges, merging them is just an ordered concatenation - the binary
+// equivalent of the append SingleFileWriter does for the text format.
+func (w *SortedBinWriter) Finalize() error {
+	files, err := os.ReadDir(w.Folder)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	sort.Strings(names)
+
+	out, err := os.Create(w.OutputFile)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	for _, name := range names {
+		if err := appendFile(out, filepath.Join(w.Folder, name)); err != nil {
+			return err
+		}
+	}
+
+	return os.RemoveAll(w.Folder)
+}
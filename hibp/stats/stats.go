@@ -0,0 +1,94 @@
+// Package stats holds the atomic counters collected while downloading
+// password hash ranges and a helper to print them in the CLI's summary
+// format.
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Stats tracks download progress and Cloudflare cache behaviour across all
+// workers. All fields are safe for concurrent use.
+type Stats struct {
+	HashesDownloaded           atomic.Uint64
+	CloudflareRequests         atomic.Uint64
+	CloudflareHits             atomic.Uint64
+	CloudflareMisses           atomic.Uint64
+	CloudflareRequestTimeTotal atomic.Uint64
+	CloudflareNotModified      atomic.Uint64
+	DiskCacheHits              atomic.Uint64
+}
+
+// New returns an empty Stats ready to be shared across workers.
+func New() *Stats {
+	return &Stats{}
+}
+
+// AddHashesDownloaded increments the total number of hashes written to the
+// output by n.
+func (s *Stats) AddHashesDownloaded(n uint64) {
+	s.HashesDownloaded.Add(n)
+}
+
+// RecordRequest records the outcome of a single range request that actually
+// went over the wire to Cloudflare: the Cf-Cache-Status header value and how
+// long the request took. Responses served from the local on-disk cache never
+// reach Cloudflare and must be recorded with RecordDiskCacheHit instead.
+func (s *Stats) RecordRequest(cfCacheStatus string, duration time.Duration) {
+	s.CloudflareRequests.Add(1)
+	if cfCacheStatus == "HIT" {
+		s.CloudflareHits.Add(1)
+	} else {
+		s.CloudflareMisses.Add(1)
+	}
+	s.CloudflareRequestTimeTotal.Add(uint64(duration.Milliseconds()))
+}
+
+// RecordDiskCacheHit records a prefix that was served from the local
+// on-disk cache without issuing a request to Cloudflare.
+func (s *Stats) RecordDiskCacheHit() {
+	s.DiskCacheHits.Add(1)
+}
+
+// AddNotModified records n prefixes that HIBP reported unchanged (304 Not
+// Modified) in response to an If-None-Match request, and so were not
+// re-downloaded.
+func (s *Stats) AddNotModified(n uint64) {
+	s.CloudflareNotModified.Add(n)
+}
+
+// HitRatePercent returns the share of requests served from Cloudflare's
+// cache, as a whole percentage. It returns 0 if no requests were recorded.
+func (s *Stats) HitRatePercent() uint64 {
+	requests := s.CloudflareRequests.Load()
+	if requests == 0 {
+		return 0
+	}
+	return s.CloudflareHits.Load() * 100 / requests
+}
+
+// AverageRequestTimeMillis returns the average request duration in
+// milliseconds. It returns 0 if no requests were recorded.
+func (s *Stats) AverageRequestTimeMillis() uint64 {
+	requests := s.CloudflareRequests.Load()
+	if requests == 0 {
+		return 0
+	}
+	return s.CloudflareRequestTimeTotal.Load() / requests
+}
+
+// Print writes the summary block shown at the end of a download run to w.
+func (s *Stats) Print(w io.Writer) {
+	fmt.Fprintf(w, "Hashes downloaded:               %d\n", s.HashesDownloaded.Load())
+	fmt.Fprintf(w, "Cloudflare requests:             %d\n", s.CloudflareRequests.Load())
+	fmt.Fprintf(w, "Cloudflare hits:                 %d\n", s.CloudflareHits.Load())
+	fmt.Fprintf(w, "Cloudflare misses:               %d\n", s.CloudflareMisses.Load())
+	fmt.Fprintf(w, "Cloudflare not modified:         %d\n", s.CloudflareNotModified.Load())
+	fmt.Fprintf(w, "Disk cache hits:                 %d\n", s.DiskCacheHits.Load())
+	fmt.Fprintf(w, "Cloudflare hit rate:             %d %%\n", s.HitRatePercent())
+	fmt.Fprintf(w, "Cloudflare request time total:   %d ms\n", s.CloudflareRequestTimeTotal.Load())
+	fmt.Fprintf(w, "Cloudflare request time average: %d ms\n", s.AverageRequestTimeMillis())
+}
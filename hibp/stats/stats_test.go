@@ -0,0 +1,97 @@
+package stats
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordRequestTracksHitsAndMisses(t *testing.T) {
+	s := New()
+	s.RecordRequest("HIT", 100*time.Millisecond)
+	s.RecordRequest("MISS", 300*time.Millisecond)
+	s.RecordRequest("EXPIRED", 200*time.Millisecond)
+
+	if got := s.CloudflareRequests.Load(); got != 3 {
+		t.Errorf("CloudflareRequests = %d, want 3", got)
+	}
+	if got := s.CloudflareHits.Load(); got != 1 {
+		t.Errorf("CloudflareHits = %d, want 1", got)
+	}
+	if got := s.CloudflareMisses.Load(); got != 2 {
+		t.Errorf("CloudflareMisses = %d, want 2", got)
+	}
+	if got := s.HitRatePercent(); got != 33 {
+		t.Errorf("HitRatePercent() = %d, want 33", got)
+	}
+	if got := s.AverageRequestTimeMillis(); got != 200 {
+		t.Errorf("AverageRequestTimeMillis() = %d, want 200", got)
+	}
+}
+
+func TestRecordDiskCacheHitDoesNotAffectCloudflareStats(t *testing.T) {
+	s := New()
+	s.RecordDiskCacheHit()
+	s.RecordDiskCacheHit()
+	s.RecordRequest("HIT", 50*time.Millisecond)
+
+	if got := s.DiskCacheHits.Load(); got != 2 {
+		t.Errorf("DiskCacheHits = %d, want 2", got)
+	}
+	if got := s.CloudflareRequests.Load(); got != 1 {
+		t.Errorf("CloudflareRequests = %d, want 1 (disk cache hits must not count)", got)
+	}
+	if got := s.HitRatePercent(); got != 100 {
+		t.Errorf("HitRatePercent() = %d, want 100", got)
+	}
+}
+
+func TestHitRatePercentAndAverageWithNoRequests(t *testing.T) {
+	s := New()
+	if got := s.HitRatePercent(); got != 0 {
+		t.Errorf("HitRatePercent() = %d, want 0", got)
+	}
+	if got := s.AverageRequestTimeMillis(); got != 0 {
+		t.Errorf("AverageRequestTimeMillis() = %d, want 0", got)
+	}
+}
+
+func TestAddHashesDownloadedAndNotModified(t *testing.T) {
+	s := New()
+	s.AddHashesDownloaded(10)
+	s.AddHashesDownloaded(5)
+	s.AddNotModified(3)
+
+	if got := s.HashesDownloaded.Load(); got != 15 {
+		t.Errorf("HashesDownloaded = %d, want 15", got)
+	}
+	if got := s.CloudflareNotModified.Load(); got != 3 {
+		t.Errorf("CloudflareNotModified = %d, want 3", got)
+	}
+}
+
+func TestPrintIncludesAllCounters(t *testing.T) {
+	s := New()
+	s.AddHashesDownloaded(42)
+	s.RecordRequest("HIT", 10*time.Millisecond)
+	s.RecordDiskCacheHit()
+	s.AddNotModified(1)
+
+	var buf strings.Builder
+	s.Print(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		"Hashes downloaded:               42",
+		"Cloudflare requests:             1",
+		"Cloudflare hits:                 1",
+		"Cloudflare misses:               0",
+		"Cloudflare not modified:         1",
+		"Disk cache hits:                 1",
+		"Cloudflare hit rate:             100 %",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Print() output missing %q, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Package cache is a content-addressed, on-disk cache for downloaded
+// hash-prefix range bodies, keyed by the HIBP ETag they were served with.
+// It decorates a client.Fetcher, so a Downloader can use it as a drop-in
+// replacement for a plain *client.Client, and deduplicates concurrent
+// requests for the same prefix so only one of them hits the network.
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"hibp_pw_downloader.rasc.ch/hibp/client"
+)
+
+// DirName is the subdirectory created under the user cache directory when
+// no explicit cache directory is configured.
+const DirName = "hibp-passwords-downloader"
+
+// MaxAge sentinel values, following Hugo's cache flag convention.
+const (
+	// NeverExpire keeps every cached entry regardless of age.
+	NeverExpire time.Duration = -1
+	// Disabled stops serving entries from disk up front: every request goes
+	// to Next. Verified bodies are still stored, and a stored body still
+	// answers a 304 Not Modified from Next.
+	Disabled time.Duration = 0
+)
+
+// Cache is a client.Fetcher that serves hash-prefix ranges from a local,
+// ETag-keyed disk cache when possible, falling back to Next otherwise. The
+// zero value is not usable; construct one with New.
+type Cache struct {
+	// Dir is the cache root; each prefix gets its own subdirectory holding
+	// one file per ETag it has ever been seen with.
+	Dir string
+	// MaxAge bounds how long a cached entry is trusted. NeverExpire and
+	// Disabled select those behaviours regardless of sign or zero value.
+	MaxAge time.Duration
+	// Next performs the actual HTTP fetch on a cache miss.
+	Next client.Fetcher
+
+	inFlight sync.Map // hexPrefix -> *call, deduplicates concurrent misses
+}
+
+type call struct {
+	done chan struct{}
+	resp *client.Response
+	err  error
+}
+
+// New returns a Cache rooted at dir, delegating misses to next.
+func New(dir string, maxAge time.Duration, next client.Fetcher) *Cache {
+	return &Cache{Dir: dir, MaxAge: maxAge, Next: next}
+}
+
+var _ client.Fetcher = (*Cache)(nil)
+
+// Fetch implements client.Fetcher. If etag is known and a matching cache
+// entry is fresh, it is returned without touching the network. Otherwise
+// the request is forwarded to Next (sending etag as If-None-Match, so a
+// 304 response lets a fresh copy be served from disk), its body is
+// verified against the ETag's SHA-1, and the result is cached for next
+// time.
+func (c *Cache) Fetch(ctx context.Context, hexPrefix, etag string) (*client.Response, error) {
+	if etag != "" && c.MaxAge != Disabled {
+		if body, ok := c.read(hexPrefix, etag); ok {
+			return &client.Response{HexPrefix: hexPrefix, Body: body, ETag: etag, CacheStatus: "DISK"}, nil
+		}
+	}
+
+	actual, shared := c.inFlight.LoadOrStore(hexPrefix, &call{done: make(chan struct{})})
+	cl := actual.(*call)
+	if shared {
+		<-cl.done
+	} else {
+		cl.resp, cl.err = c.Next.Fetch(ctx, hexPrefix, etag)
+		close(cl.done)
+		c.inFlight.Delete(hexPrefix)
+	}
+	if cl.err != nil {
+		return nil, cl.err
+	}
+
+	if cl.resp.NotModified {
+		body, ok := c.read(hexPrefix, etag)
+		if !ok {
+			return nil, fmt.Errorf("prefix %s: server reported 304 Not Modified but no cached body for ETag %s", hexPrefix, etag)
+		}
+		resp := *cl.resp
+		resp.Body = body
+		resp.NotModified = false
+		return &resp, nil
+	}
+
+	if err := c.verifyAndStore(hexPrefix, cl.resp); err != nil {
+		return nil, err
+	}
+	return cl.resp, nil
+}
+
+func (c *Cache) path(hexPrefix, etag string) string {
+	return filepath.Join(c.Dir, hexPrefix, sanitizeETag(etag))
+}
+
+func (c *Cache) read(hexPrefix, etag string) ([]byte, bool) {
+	path := c.path(hexPrefix, etag)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	if c.MaxAge > 0 && time.Since(info.ModTime()) > c.MaxAge {
+		return nil, false
+	}
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+func (c *Cache) verifyAndStore(hexPrefix string, resp *client.Response) error {
+	if resp.ETag == "" {
+		return nil
+	}
+
+	sum := sha1.Sum(resp.Body)
+	got := hex.EncodeToString(sum[:])
+	want := sanitizeETag(resp.ETag)
+	if !strings.EqualFold(got, want) {
+		return fmt.Errorf("prefix %s: downloaded body sha1 %s does not match ETag %s", hexPrefix, got, want)
+	}
+
+	if err := os.MkdirAll(filepath.Join(c.Dir, hexPrefix), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path(hexPrefix, resp.ETag), resp.Body, 0o644)
+}
+
+func sanitizeETag(etag string) string {
+	return strings.Trim(etag, `"`)
+}
+
+// ParseMaxAge parses a --cache-max-age flag value: "-1" for NeverExpire,
+// "0" for Disabled, or any duration string accepted by time.ParseDuration.
+func ParseMaxAge(s string) (time.Duration, error) {
+	switch s {
+	case "-1":
+		return NeverExpire, nil
+	case "0":
+		return Disabled, nil
+	default:
+		return time.ParseDuration(s)
+	}
+}
@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hibp_pw_downloader.rasc.ch/hibp/client"
+)
+
+// fakeFetcher is a client.Fetcher that counts calls and blocks until
+// release is closed, so tests can control exactly when a "network" fetch
+// completes and observe how many were actually issued.
+type fakeFetcher struct {
+	calls   atomic.Int32
+	release chan struct{}
+	resp    *client.Response
+	err     error
+}
+
+func newFakeFetcher(resp *client.Response, err error) *fakeFetcher {
+	return &fakeFetcher{release: make(chan struct{}), resp: resp, err: err}
+}
+
+func (f *fakeFetcher) Fetch(ctx context.Context, hexPrefix, etag string) (*client.Response, error) {
+	f.calls.Add(1)
+	<-f.release
+	if f.err != nil {
+		return nil, f.err
+	}
+	resp := *f.resp
+	return &resp, nil
+}
+
+func etagFor(body []byte) string {
+	sum := sha1.Sum(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func TestCacheFetchVerifiesAndStores(t *testing.T) {
+	body := []byte("0000000000000000000A:5\n")
+	etag := etagFor(body)
+
+	fake := newFakeFetcher(&client.Response{HexPrefix: "00000", Body: body, ETag: etag}, nil)
+	close(fake.release)
+
+	c := New(t.TempDir(), NeverExpire, fake)
+	resp, err := c.Fetch(context.Background(), "00000", "")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if string(resp.Body) != string(body) {
+		t.Errorf("Body = %q, want %q", resp.Body, body)
+	}
+	if fake.calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", fake.calls.Load())
+	}
+
+	// A second fetch with the same ETag must be served from disk, without
+	// calling Next again.
+	resp2, err := c.Fetch(context.Background(), "00000", etag)
+	if err != nil {
+		t.Fatalf("second Fetch: %v", err)
+	}
+	if resp2.CacheStatus != "DISK" {
+		t.Errorf("CacheStatus = %q, want DISK", resp2.CacheStatus)
+	}
+	if string(resp2.Body) != string(body) {
+		t.Errorf("Body = %q, want %q", resp2.Body, body)
+	}
+	if fake.calls.Load() != 1 {
+		t.Errorf("calls after cached fetch = %d, want still 1", fake.calls.Load())
+	}
+}
+
+func TestCacheFetchRejectsBodyThatDoesNotMatchETag(t *testing.T) {
+	body := []byte("0000000000000000000A:5\n")
+	wrongETag := etagFor([]byte("not the body"))
+
+	fake := newFakeFetcher(&client.Response{HexPrefix: "00000", Body: body, ETag: wrongETag}, nil)
+	close(fake.release)
+
+	c := New(t.TempDir(), NeverExpire, fake)
+	if _, err := c.Fetch(context.Background(), "00000", ""); err == nil {
+		t.Fatal("Fetch() = nil error for a body that doesn't match its ETag, want an error")
+	}
+}
+
+func TestCacheFetchServesNotModifiedFromDisk(t *testing.T) {
+	body := []byte("0000000000000000000A:5\n")
+	etag := etagFor(body)
+	dir := t.TempDir()
+
+	// With MaxAge Disabled the front-line disk read is skipped (every
+	// request goes to Next), but the on-disk body is still used to avoid
+	// re-downloading content the server reports unchanged via 304.
+	fake := newFakeFetcher(&client.Response{HexPrefix: "00000", Body: body, ETag: etag}, nil)
+	close(fake.release)
+	c := New(dir, Disabled, fake)
+	if _, err := c.Fetch(context.Background(), "00000", ""); err != nil {
+		t.Fatalf("priming Fetch: %v", err)
+	}
+	if fake.calls.Load() != 1 {
+		t.Fatalf("priming calls = %d, want 1", fake.calls.Load())
+	}
+
+	notModified := newFakeFetcher(&client.Response{HexPrefix: "00000", ETag: etag, NotModified: true}, nil)
+	close(notModified.release)
+	c2 := New(dir, Disabled, notModified)
+	resp, err := c2.Fetch(context.Background(), "00000", etag)
+	if err != nil {
+		t.Fatalf("Fetch after 304: %v", err)
+	}
+	if notModified.calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1: Disabled must still ask Next every time", notModified.calls.Load())
+	}
+	if string(resp.Body) != string(body) {
+		t.Errorf("Body after 304 = %q, want %q", resp.Body, body)
+	}
+}
+
+func TestCacheFetchNotModifiedWithoutCachedBodyFails(t *testing.T) {
+	notModified := newFakeFetcher(&client.Response{HexPrefix: "00000", ETag: `"deadbeef"`, NotModified: true}, nil)
+	close(notModified.release)
+	c := New(t.TempDir(), NeverExpire, notModified)
+
+	if _, err := c.Fetch(context.Background(), "00000", `"deadbeef"`); err == nil {
+		t.Fatal("Fetch() = nil error for a 304 with no cached body on disk, want an error")
+	}
+}
+
+func TestCacheFetchDeduplicatesConcurrentRequests(t *testing.T) {
+	body := []byte("0000000000000000000A:5\n")
+	etag := etagFor(body)
+	fake := newFakeFetcher(&client.Response{HexPrefix: "00000", Body: body, ETag: etag}, nil)
+
+	c := New(t.TempDir(), NeverExpire, fake)
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = c.Fetch(context.Background(), "00000", "")
+		}(i)
+	}
+
+	// Give every goroutine a chance to register itself as in-flight before
+	// letting the single "network" request complete.
+	time.Sleep(50 * time.Millisecond)
+	close(fake.release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("worker %d: %v", i, err)
+		}
+	}
+	if got := fake.calls.Load(); got != 1 {
+		t.Errorf("Next.Fetch called %d times for %d concurrent requests, want 1", got, workers)
+	}
+}
+
+func TestCacheFetchRespectsMaxAge(t *testing.T) {
+	body := []byte("0000000000000000000A:5\n")
+	etag := etagFor(body)
+	dir := t.TempDir()
+
+	fake := newFakeFetcher(&client.Response{HexPrefix: "00000", Body: body, ETag: etag}, nil)
+	close(fake.release)
+	c := New(dir, NeverExpire, fake)
+	if _, err := c.Fetch(context.Background(), "00000", ""); err != nil {
+		t.Fatalf("priming Fetch: %v", err)
+	}
+
+	fake2 := newFakeFetcher(&client.Response{HexPrefix: "00000", Body: body, ETag: etag}, nil)
+	close(fake2.release)
+	cExpired := New(dir, time.Nanosecond, fake2)
+	time.Sleep(time.Millisecond)
+	if _, err := cExpired.Fetch(context.Background(), "00000", etag); err != nil {
+		t.Fatalf("Fetch with expired entry: %v", err)
+	}
+	if fake2.calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1: an entry older than MaxAge must not be served from disk", fake2.calls.Load())
+	}
+}
+
+func TestParseMaxAge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"-1", NeverExpire, false},
+		{"0", Disabled, false},
+		{"24h", 24 * time.Hour, false},
+		{"not-a-duration", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMaxAge(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMaxAge(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("ParseMaxAge(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}